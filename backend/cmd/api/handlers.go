@@ -2,11 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/iankencruz/eggcounter/backend/internal/models"
@@ -42,12 +45,41 @@ func (app *Application) dashboardHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Fetch totals per egg type
+	totalByType, err := app.EggTypeModel.GetTotalsByType(r.Context(), userID)
+	if err != nil {
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to fetch egg totals by type")
+		return
+	}
+
 	// Fetch recent entries
-	recentEntries, err := app.EggModel.GetRecentEggEntries(r.Context(), userID, 5)
+	recentEntries, err := app.EggModel.GetRecentEggEntries(r.Context(), userID, 5, models.EntryFilter{})
 	if err != nil {
 		SendJSON(w, http.StatusInternalServerError, nil, "Failed to fetch recent egg entries")
 		return
 	}
+	if err := app.NutritionModel.Annotate(r.Context(), recentEntries); err != nil {
+		log.Printf("Error loading nutrition: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to fetch recent egg entries")
+		return
+	}
+
+	// Fetch goal progress
+	progress, err := app.goalProgress(r, userID, time.Now())
+	if err != nil {
+		log.Printf("Error fetching goal progress: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to fetch goal progress")
+		return
+	}
+
+	// Fetch streaks, measured against the user's goals where they have them
+	minimum, limit := streakThresholds(&progress.Goals)
+	streaks, err := app.userStreaks(r, userID, minimum, limit, true)
+	if err != nil {
+		log.Printf("Error computing streaks: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to compute streaks")
+		return
+	}
 
 	// Build the dashboard response
 	data := map[string]interface{}{
@@ -58,7 +90,10 @@ func (app *Application) dashboardHandler(w http.ResponseWriter, r *http.Request)
 			"username":  user.Username,
 		},
 		"totalEggs":     totalEggs,
+		"totalByType":   totalByType,
 		"recentEntries": recentEntries,
+		"streaks":       streaks,
+		"goals":         progress,
 	}
 
 	// Send JSON response
@@ -84,10 +119,8 @@ func (app *Application) registerHandler(w http.ResponseWriter, r *http.Request)
 	} else if !isValidEmail(email) {
 		errors["email"] = "Invalid email format"
 	}
-	if password == "" {
-		errors["password"] = "Password is required"
-	} else if len(password) < 8 {
-		errors["password"] = "Password must be at least 8 characters"
+	if msg := validatePassword(password); msg != "" {
+		errors["password"] = msg
 	}
 
 	// If there are validation errors, return them
@@ -103,7 +136,7 @@ func (app *Application) registerHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Create user in the database
-	err := app.UserModel.CreateUser(r.Context(), username, firstname, lastname, email, password)
+	userID, err := app.UserModel.CreateUser(r.Context(), username, firstname, lastname, email, password)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -116,11 +149,18 @@ func (app *Application) registerHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// The account works straight away; the user can ask for another link if
+	// this one doesn't arrive.
+	user := &models.User{ID: userID, Username: username, Email: email}
+	if err := app.startEmailVerification(r.Context(), user); err != nil {
+		log.Printf("Error starting email verification: %v", err)
+	}
+
 	// Success response
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"data":    nil,
-		"message": "User registered successfully",
+		"message": "User registered successfully. Check your email to verify your address.",
 		"errors":  nil,
 		"status":  http.StatusCreated,
 	})
@@ -143,9 +183,15 @@ func (app *Application) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse attempts while the account or client is blocked
+	if !app.checkLoginThrottle(w, r, req.Email) {
+		return
+	}
+
 	// Authenticate user
 	user, err := app.UserModel.AuthenticateUser(r.Context(), req.Email, req.Password)
 	if err != nil {
+		app.recordLoginFailure(r, req.Email)
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"message": "Invalid credentials",
@@ -154,14 +200,71 @@ func (app *Application) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// With two-factor authentication on, the password alone doesn't log in
+	enabled, err := app.TwoFactorModel.IsEnabled(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error checking two-factor authentication: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to log in")
+		return
+	}
+	if enabled {
+		app.startTwoFactorLogin(w, r, user.ID)
+		return
+	}
+
+	app.completeLogin(w, r, user, nil)
+}
+
+// completeLogin logs the session in as the user once every factor has been
+// checked. extra is added to the response.
+func (app *Application) completeLogin(w http.ResponseWriter, r *http.Request, user *models.User, extra map[string]interface{}) {
+	userID := user.ID
+	app.resetLoginFailures(r, user.Email)
+
+	// Start a fresh session so a token issued before login can't be reused
+	if err := app.Session.RenewToken(r.Context()); err != nil {
+		log.Printf("Error renewing session token: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to log in")
+		return
+	}
+
+	// Sessions are only valid for the epoch they logged in at
+	epoch, err := app.SessionModel.GetSessionEpoch(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching session epoch: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to log in")
+		return
+	}
+
 	// Store user ID in session
-	app.Session.Put(r.Context(), "userID", user.ID)
+	app.clearPendingTwoFactor(r)
+	app.Session.Put(r.Context(), "userID", userID)
+	app.Session.Put(r.Context(), "sessionEpoch", epoch)
+
+	err = app.SessionModel.RecordSession(r.Context(), app.Session.Token(r.Context()), userID, clientIP(r), r.UserAgent())
+	if err != nil {
+		log.Printf("Error recording session: %v", err)
+	}
+
+	// Logging in during the grace period keeps an account scheduled for deletion
+	message := "Login successful"
+	restored, err := app.UserModel.CancelDeletion(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error cancelling account deletion: %v", err)
+	} else if restored {
+		message = "Login successful. Your account is no longer scheduled for deletion."
+	}
 
 	// Send success response
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Login successful",
-		"status":  http.StatusOK,
-	})
+	response := map[string]interface{}{
+		"message":  message,
+		"status":   http.StatusOK,
+		"restored": restored,
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 func (app *Application) logoutHandler(w http.ResponseWriter, r *http.Request) {
@@ -171,6 +274,17 @@ func (app *Application) logoutHandler(w http.ResponseWriter, r *http.Request) {
 
 func (app *Application) authStatusHandler(w http.ResponseWriter, r *http.Request) {
 	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID != 0 {
+		current, err := app.sessionCurrent(r, userID)
+		if err != nil {
+			log.Printf("Error checking session epoch: %v", err)
+			http.Error(w, "Failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if !current {
+			userID = 0
+		}
+	}
 
 	if userID == 0 {
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -203,39 +317,178 @@ func (app *Application) getEggCountHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	byType, err := app.EggTypeModel.GetTotalsByType(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch egg count", http.StatusInternalServerError)
+		return
+	}
+
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"totalEggs": total,
+		"totalEggs":   total,
+		"totalByType": byType,
 	})
 }
 
 func (app *Application) addEggCountHandler(w http.ResponseWriter, r *http.Request) {
 	userID := app.Session.GetInt(r.Context(), "userID")
 	if userID == 0 {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
 		return
 	}
 
+	// eaten_at is optional (RFC 3339) and defaults to now, so entries can be
+	// backdated. egg_type_id defaults to a large chicken egg. meal, note and
+	// tags are optional context.
 	type Request struct {
-		Amount int `json:"amount"`
+		Amount    int        `json:"amount"`
+		EatenAt   *time.Time `json:"eaten_at"`
+		EggTypeID *int       `json:"egg_type_id"`
+		Meal      string     `json:"meal"`
+		Note      string     `json:"note"`
+		Tags      []string   `json:"tags"`
 	}
 
 	var req Request
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Amount <= 0 {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid input")
+		return
+	}
+
+	req.Note = strings.TrimSpace(req.Note)
+	req.Tags = models.NormalizeTags(req.Tags)
+	if msg := validateEntryContext(&req.Meal, &req.Note, req.Tags); msg != "" {
+		SendJSON(w, http.StatusBadRequest, nil, msg)
+		return
+	}
+
+	newEntry := models.NewEntry{
+		Amount:    req.Amount,
+		EggTypeID: req.EggTypeID,
+		Meal:      req.Meal,
+		Note:      req.Note,
+		Tags:      req.Tags,
+	}
+	if req.EatenAt != nil {
+		newEntry.EatenAt = *req.EatenAt
+	}
+
+	// Progress before the add tells us which limits this entry pushed the
+	// user over, on the day and in the week it was eaten.
+	eatenAt := time.Now()
+	if req.EatenAt != nil {
+		eatenAt = *req.EatenAt
+	}
+	before, err := app.goalProgress(r, userID, eatenAt)
+	if err != nil {
+		log.Printf("Error fetching goal progress: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to add egg count")
 		return
 	}
 
-	err := app.EggModel.AddEggCount(r.Context(), userID, req.Amount)
+	entry, err := app.EggModel.AddEggCount(r.Context(), userID, newEntry)
 	if err != nil {
-		http.Error(w, "Failed to add egg count", http.StatusInternalServerError)
+		sendEntryError(w, err, "add egg count")
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Egg count added successfully",
+	annotated := []models.EggCount{*entry}
+	if err := app.NutritionModel.Annotate(r.Context(), annotated); err != nil {
+		log.Printf("Error loading nutrition: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Egg count added, but failed to load its nutrition")
+		return
+	}
+	entry = &annotated[0]
+
+	after, err := app.goalProgress(r, userID, entry.EatenAt)
+	if err != nil {
+		log.Printf("Error fetching goal progress: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Egg count added, but failed to load goal progress")
+		return
+	}
+
+	limitExceeded := newlyExceeded(before, after)
+
+	data := map[string]interface{}{
+		"entry":          entry,
+		"progress":       after,
+		"limit_exceeded": limitExceeded,
+	}
+
+	message := "Egg count added successfully"
+	if len(limitExceeded) > 0 {
+		message = "Egg count added, but you are now over your limit"
+	}
+	SendJSON(w, http.StatusCreated, data, message)
+}
+
+func (app *Application) updateEggEntryHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	entryID, err := intURLParam(r, "id")
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid entry ID")
+		return
+	}
+
+	// All fields are optional; omitted fields are left unchanged. An empty
+	// meal or note clears it, and an empty tags list removes every tag.
+	var req struct {
+		Amount    *int       `json:"amount"`
+		EatenAt   *time.Time `json:"eaten_at"`
+		EggTypeID *int       `json:"egg_type_id"`
+		Meal      *string    `json:"meal"`
+		Note      *string    `json:"note"`
+		Tags      *[]string  `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid input")
+		return
+	}
+	if req.Amount == nil && req.EatenAt == nil && req.EggTypeID == nil &&
+		req.Meal == nil && req.Note == nil && req.Tags == nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Nothing to update")
+		return
+	}
+	if req.Amount != nil && *req.Amount <= 0 {
+		SendJSON(w, http.StatusBadRequest, nil, "Amount must be greater than zero")
+		return
+	}
+
+	var tags []string
+	if req.Note != nil {
+		*req.Note = strings.TrimSpace(*req.Note)
+	}
+	if req.Tags != nil {
+		tags = models.NormalizeTags(*req.Tags)
+		req.Tags = &tags
+	}
+	if msg := validateEntryContext(req.Meal, req.Note, tags); msg != "" {
+		SendJSON(w, http.StatusBadRequest, nil, msg)
+		return
+	}
+
+	entry, err := app.EggModel.UpdateEggEntry(r.Context(), userID, entryID, models.EntryUpdate{
+		Amount:    req.Amount,
+		EatenAt:   req.EatenAt,
+		EggTypeID: req.EggTypeID,
+		Meal:      req.Meal,
+		Note:      req.Note,
+		Tags:      req.Tags,
 	})
+	if err != nil {
+		sendEntryError(w, err, "update entry")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, entry, "Entry updated successfully")
 }
 
+// deleteEntryHandler undoes an entry. Undo is idempotent: undoing an entry
+// that is already reverted succeeds without recording another reversal.
 func (app *Application) deleteEntryHandler(w http.ResponseWriter, r *http.Request) {
 	userID := app.Session.GetInt(r.Context(), "userID")
 	if userID == 0 {
@@ -243,54 +496,156 @@ func (app *Application) deleteEntryHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Extract entry ID from URL
-	entryID := chi.URLParam(r, "id")
-	if entryID == "" {
-		SendJSON(w, http.StatusBadRequest, nil, "Entry ID is required")
+	id, err := intURLParam(r, "id")
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid entry ID")
+		return
+	}
+
+	entry, changed, err := app.EggModel.UndoEntry(r.Context(), userID, id)
+	if err != nil {
+		sendEntryError(w, err, "undo entry")
+		return
+	}
+
+	if !changed {
+		SendJSON(w, http.StatusOK, entry, "Entry was already undone")
+		return
+	}
+	SendJSON(w, http.StatusOK, entry, "Entry successfully undone")
+}
+
+func (app *Application) redoEntryHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
 		return
 	}
 
-	// Convert entryID to an integer
-	id, err := strconv.Atoi(entryID)
+	id, err := intURLParam(r, "id")
 	if err != nil {
 		SendJSON(w, http.StatusBadRequest, nil, "Invalid entry ID")
 		return
 	}
 
-	// Get the amount for this entry
-	amount, err := app.EggModel.GetEntryByID(r.Context(), userID, id)
+	entry, changed, err := app.EggModel.RedoEntry(r.Context(), userID, id)
 	if err != nil {
-		SendJSON(w, http.StatusInternalServerError, nil, "Failed to retrieve entry amount")
+		sendEntryError(w, err, "redo entry")
+		return
+	}
+
+	if !changed {
+		SendJSON(w, http.StatusOK, entry, "Entry is already active")
 		return
 	}
+	SendJSON(w, http.StatusOK, entry, "Entry successfully restored")
+}
 
-	// Insert a new "undo" entry (insert a negative amount)
-	err = app.EggModel.InsertNegativeEntry(r.Context(), userID, amount)
+// sendEntryError maps EggModel errors to HTTP responses. action describes
+// what failed, e.g. "undo entry".
+func sendEntryError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		SendJSON(w, http.StatusNotFound, nil, "Entry not found")
+	case errors.Is(err, models.ErrReversalEntry):
+		SendJSON(w, http.StatusBadRequest, nil, "Reversal entries cannot be changed")
+	case errors.Is(err, models.ErrEatenAtInFuture):
+		SendJSON(w, http.StatusBadRequest, nil, "eaten_at cannot be in the future")
+	case errors.Is(err, models.ErrUnknownEggType):
+		SendJSON(w, http.StatusBadRequest, nil, "Unknown egg type")
+	default:
+		log.Printf("Failed to %s: %v", action, err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to "+action)
+	}
+}
+
+// USER HANDLERS
+
+func (app *Application) searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+	if !app.checkVerified(w, r, userID, restrictUserSearch) {
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(q) < 2 {
+		SendJSON(w, http.StatusBadRequest, nil, "Search query must be at least 2 characters")
+		return
+	}
+
+	page, err := readIntQuery(r, "page", 1)
+	if err != nil || page < 1 {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid page")
+		return
+	}
+	pageSize, err := readIntQuery(r, "page_size", 20)
+	if err != nil || pageSize < 1 || pageSize > 50 {
+		SendJSON(w, http.StatusBadRequest, nil, "page_size must be between 1 and 50")
+		return
+	}
+
+	// Fetch one extra row to know whether another page exists.
+	users, err := app.UserModel.SearchUsers(r.Context(), userID, q, pageSize+1, (page-1)*pageSize)
 	if err != nil {
-		SendJSON(w, http.StatusInternalServerError, nil, "Failed to add reversal entry")
+		log.Printf("Error searching users: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to search users")
 		return
 	}
 
-	SendJSON(w, http.StatusOK, nil, "Entry successfully undone")
+	hasMore := len(users) > pageSize
+	if hasMore {
+		users = users[:pageSize]
+	}
+
+	data := map[string]interface{}{
+		"users":     users,
+		"page":      page,
+		"page_size": pageSize,
+		"has_more":  hasMore,
+	}
+
+	SendJSON(w, http.StatusOK, data, "Users retrieved successfully")
 }
 
 // FRIENDS HANDLERS
 
 func (app *Application) sendFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
+	// The receiver can be given by ID or, when the ID isn't known, by username.
 	var req struct {
-		ReceiverID int `json:"receiver_id"`
+		ReceiverID int    `json:"receiver_id"`
+		Username   string `json:"username"`
 	}
-	json.NewDecoder(r.Body).Decode(&req)
 
 	senderID := app.Session.GetInt(r.Context(), "userID")
 	if senderID == 0 {
 		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized")
 		return
 	}
+	if !app.checkVerified(w, r, senderID, restrictFriendRequests) {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.ReceiverID <= 0 && req.Username == "") {
+		SendJSON(w, http.StatusBadRequest, nil, "A valid receiver_id or username is required")
+		return
+	}
+
+	if req.ReceiverID <= 0 {
+		receiver, err := app.UserModel.GetUserByUsername(r.Context(), req.Username)
+		if err != nil {
+			sendFriendError(w, err, "send friend request")
+			return
+		}
+		req.ReceiverID = receiver.ID
+	}
 
 	err := app.FriendModel.SendFriendRequest(r.Context(), senderID, req.ReceiverID)
 	if err != nil {
-		SendJSON(w, http.StatusInternalServerError, nil, "Failed to send friend request")
+		sendFriendError(w, err, "send friend request")
 		return
 	}
 
@@ -312,9 +667,15 @@ func (app *Application) acceptFriendRequestHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
-	err := app.FriendModel.AcceptFriendRequest(r.Context(), friendID)
+	requestID, err := strconv.Atoi(friendID)
 	if err != nil {
-		SendJSON(w, http.StatusInternalServerError, nil, "Failed to accept friend request")
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid friend request ID")
+		return
+	}
+
+	err = app.FriendModel.AcceptFriendRequest(r.Context(), userID, requestID)
+	if err != nil {
+		sendFriendError(w, err, "accept friend request")
 		return
 	}
 
@@ -336,9 +697,15 @@ func (app *Application) rejectFriendRequestHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
-	err := app.FriendModel.RejectFriendRequest(r.Context(), friendID)
+	requestID, err := strconv.Atoi(friendID)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid friend request ID")
+		return
+	}
+
+	err = app.FriendModel.RejectFriendRequest(r.Context(), userID, requestID)
 	if err != nil {
-		SendJSON(w, http.StatusInternalServerError, nil, "Failed to reject friend request")
+		sendFriendError(w, err, "reject friend request")
 		return
 	}
 
@@ -379,3 +746,148 @@ func (app *Application) getFriendRequestsHandler(w http.ResponseWriter, r *http.
 
 	SendJSON(w, http.StatusOK, friendRequests, "Friend requests retrieved successfully")
 }
+
+func (app *Application) getSentFriendRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	sentRequests, err := app.FriendModel.GetSentFriendRequests(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching sent friend requests: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to retrieve sent friend requests")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, sentRequests, "Sent friend requests retrieved successfully")
+}
+
+func (app *Application) cancelFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	requestID, err := intURLParam(r, "id")
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid friend request ID")
+		return
+	}
+
+	err = app.FriendModel.CancelFriendRequest(r.Context(), userID, requestID)
+	if err != nil {
+		sendFriendError(w, err, "cancel friend request")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, nil, "Friend request cancelled")
+}
+
+func (app *Application) removeFriendHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	friendID, err := intURLParam(r, "userID")
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid user ID")
+		return
+	}
+
+	err = app.FriendModel.RemoveFriend(r.Context(), userID, friendID)
+	if err != nil {
+		sendFriendError(w, err, "remove friend")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, nil, "Friend removed")
+}
+
+func (app *Application) getBlockedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	blocked, err := app.FriendModel.GetBlockedUsers(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching blocked users: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to retrieve blocked users")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, blocked, "Blocked users retrieved successfully")
+}
+
+func (app *Application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	var req struct {
+		UserID int `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+		SendJSON(w, http.StatusBadRequest, nil, "A valid user_id is required")
+		return
+	}
+
+	err := app.FriendModel.BlockUser(r.Context(), userID, req.UserID)
+	if err != nil {
+		sendFriendError(w, err, "block user")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, nil, "User blocked")
+}
+
+func (app *Application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	blockedID, err := intURLParam(r, "userID")
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid user ID")
+		return
+	}
+
+	err = app.FriendModel.UnblockUser(r.Context(), userID, blockedID)
+	if err != nil {
+		sendFriendError(w, err, "unblock user")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, nil, "User unblocked")
+}
+
+// sendFriendError maps FriendModel errors to HTTP responses. action describes
+// what failed, e.g. "accept friend request".
+func sendFriendError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, models.ErrNoRecord), errors.Is(err, models.ErrBlocked):
+		// A block reads as not found, so nobody learns that they were blocked.
+		SendJSON(w, http.StatusNotFound, nil, "That user or friend request could not be found")
+	case errors.Is(err, models.ErrForbidden):
+		SendJSON(w, http.StatusForbidden, nil, "You are not allowed to "+action)
+	case errors.Is(err, models.ErrSelfRequest):
+		SendJSON(w, http.StatusConflict, nil, "You cannot do that to your own account")
+	case errors.Is(err, models.ErrDuplicateRequest):
+		SendJSON(w, http.StatusConflict, nil, "A friend request between you is already pending")
+	case errors.Is(err, models.ErrAlreadyFriends):
+		SendJSON(w, http.StatusConflict, nil, "You are already friends")
+	default:
+		log.Printf("Failed to %s: %v", action, err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to "+action)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+// deletionGracePeriod is how long a deleted account can still be restored
+// by logging in.
+const deletionGracePeriod = 14 * 24 * time.Hour
+
+// deleteAccountHandler schedules the user's account for deletion after the
+// grace period and logs out all of their sessions. It requires the current
+// password.
+func (app *Application) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
+		SendJSON(w, http.StatusBadRequest, nil, "Confirm with your password")
+		return
+	}
+
+	if err := app.UserModel.CheckPassword(r.Context(), userID, req.Password); err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			SendJSON(w, http.StatusForbidden, nil, "Incorrect password")
+			return
+		}
+		log.Printf("Error checking password: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to delete account")
+		return
+	}
+
+	deleteAfter, err := app.UserModel.ScheduleDeletion(r.Context(), userID, deletionGracePeriod)
+	if err != nil {
+		log.Printf("Error scheduling account deletion: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to delete account")
+		return
+	}
+
+	if _, err := app.SessionModel.DeleteUserSessions(r.Context(), userID, ""); err != nil {
+		log.Printf("Error ending sessions: %v", err)
+	}
+	if err := app.Session.Destroy(r.Context()); err != nil {
+		log.Printf("Error destroying session: %v", err)
+	}
+
+	data := map[string]interface{}{
+		"delete_after": deleteAfter,
+	}
+	SendJSON(w, http.StatusAccepted, data, "Your account will be deleted. Log in again before then to keep it.")
+}
+
+// purgeDueAccounts permanently deletes every account whose grace period has
+// passed.
+func (app *Application) purgeDueAccounts(ctx context.Context) {
+	for {
+		userID, err := app.UserModel.PurgeDueAccount(ctx)
+		if err != nil {
+			log.Printf("Error purging account: %v", err)
+			return
+		}
+		if userID == 0 {
+			return
+		}
+		log.Printf("Purged account %d", userID)
+	}
+}
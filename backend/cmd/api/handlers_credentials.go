@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/iankencruz/eggcounter/backend/internal/mailer"
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+// auditSource describes the client making a request, for audit entries.
+func auditSource(r *http.Request) models.AuditSource {
+	return models.AuditSource{IPAddress: clientIP(r), UserAgent: r.UserAgent()}
+}
+
+// changePasswordHandler replaces the user's password after checking the
+// current one. Every other session is logged out.
+func (app *Application) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid request body")
+		return
+	}
+
+	if errs := validatePasswordChange(req.CurrentPassword, req.NewPassword); len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":    nil,
+			"message": "Validation failed",
+			"errors":  errs,
+			"status":  http.StatusBadRequest,
+		})
+		return
+	}
+
+	token := app.Session.Token(r.Context())
+	err := app.UserModel.ChangePassword(r.Context(), userID, req.CurrentPassword, req.NewPassword, token, auditSource(r))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			SendJSON(w, http.StatusForbidden, nil, "Incorrect password")
+			return
+		}
+		log.Printf("Error changing password: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to change password")
+		return
+	}
+
+	// Changing the password moved the session epoch on; keep this session
+	if epoch, err := app.SessionModel.GetSessionEpoch(r.Context(), userID); err != nil {
+		log.Printf("Error fetching session epoch: %v", err)
+	} else {
+		app.Session.Put(r.Context(), "sessionEpoch", epoch)
+	}
+
+	if user, err := app.UserModel.GetUserByID(r.Context(), userID); err != nil {
+		log.Printf("Error fetching user: %v", err)
+	} else {
+		app.sendMail(mailer.Message{
+			To:      user.Email,
+			Subject: "Your Egg Counter password was changed",
+			Body: fmt.Sprintf(
+				"Hi %s,\n\nThe password for your account was just changed, and your other sessions were logged out. If this wasn't you, reset your password straight away.\n",
+				user.Username,
+			),
+		})
+	}
+
+	SendJSON(w, http.StatusOK, nil, "Password changed. Your other sessions have been logged out.")
+}
+
+func validatePasswordChange(current, password string) map[string]string {
+	errors := map[string]string{}
+	if current == "" {
+		errors["current_password"] = "Current password is required"
+	}
+	if msg := validatePassword(password); msg != "" {
+		errors["new_password"] = msg
+	}
+	return errors
+}
+
+// changeEmailHandler starts switching the user's email address. The current
+// address stays in place until the link sent to the new one is opened.
+func (app *Application) changeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+		Email    string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid request body")
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+
+	user, err := app.UserModel.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching user: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to change email")
+		return
+	}
+
+	if errs := validateEmailChange(req.Password, req.Email, user.Email); len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":    nil,
+			"message": "Validation failed",
+			"errors":  errs,
+			"status":  http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := app.UserModel.CheckPassword(r.Context(), userID, req.Password); err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			SendJSON(w, http.StatusForbidden, nil, "Incorrect password")
+			return
+		}
+		log.Printf("Error checking password: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to change email")
+		return
+	}
+
+	token, tokenHash, err := newToken()
+	if err != nil {
+		log.Printf("Error generating email change token: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to change email")
+		return
+	}
+
+	err = app.UserModel.RequestEmailChange(r.Context(), userID, req.Email, tokenHash, verificationTTL, auditSource(r))
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			SendJSON(w, http.StatusConflict, nil, "That email address is already in use")
+			return
+		}
+		log.Printf("Error requesting email change: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to change email")
+		return
+	}
+
+	link := app.BaseURL + "/api/email/confirm?token=" + url.QueryEscape(token)
+	app.sendMail(mailer.Message{
+		To:      req.Email,
+		Subject: "Confirm your new Egg Counter email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen this link within 48 hours to start using this address for your account:\n\n%s\n\nIf you didn't ask for this, you can ignore this email.\n",
+			user.Username, link,
+		),
+	})
+	app.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your Egg Counter email address is changing",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to move your account to %s. It will switch once the link sent there is opened. If this wasn't you, change your password straight away.\n",
+			user.Username, req.Email,
+		),
+	})
+
+	data := map[string]interface{}{
+		"pending_email": req.Email,
+	}
+	SendJSON(w, http.StatusAccepted, data, "Check your new email address to confirm the change")
+}
+
+func validateEmailChange(password, email, current string) map[string]string {
+	errors := map[string]string{}
+	if password == "" {
+		errors["password"] = "Password is required"
+	}
+	switch {
+	case email == "":
+		errors["email"] = "Email is required"
+	case !isValidEmail(email):
+		errors["email"] = "Invalid email format"
+	case email == current:
+		errors["email"] = "That is already your email address"
+	}
+	return errors
+}
+
+// confirmEmailChangeHandler switches the account to the new email address a
+// change link was sent to. Like email verification, it works without a
+// session.
+func (app *Application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		SendJSON(w, http.StatusBadRequest, nil, "Missing token")
+		return
+	}
+
+	if _, err := app.UserModel.ConfirmEmailChange(r.Context(), hashToken(token), auditSource(r)); err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			SendJSON(w, http.StatusBadRequest, nil, "This confirmation link is invalid or has expired")
+		case errors.Is(err, models.ErrDuplicateEmail):
+			SendJSON(w, http.StatusConflict, nil, "That email address is already in use")
+		default:
+			log.Printf("Error confirming email change: %v", err)
+			SendJSON(w, http.StatusInternalServerError, nil, "Failed to change email")
+		}
+		return
+	}
+
+	SendJSON(w, http.StatusOK, nil, "Email address changed")
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+func (app *Application) listEggTypesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	types, err := app.EggTypeModel.ListEggTypes(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching egg types: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to retrieve egg types")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, types, "Egg types retrieved successfully")
+}
+
+func (app *Application) createEggTypeHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	var req struct {
+		Species string `json:"species"`
+		Size    string `json:"size"`
+		Name    string `json:"name"`
+		// Nutrition optionally gives the values for a single egg.
+		Nutrition *models.Nutrition `json:"nutrition"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid input")
+		return
+	}
+
+	species := strings.ToLower(strings.TrimSpace(req.Species))
+	size := strings.ToLower(strings.TrimSpace(req.Size))
+	name := strings.TrimSpace(req.Name)
+	if species == "" || utf8.RuneCountInString(species) > 50 {
+		SendJSON(w, http.StatusBadRequest, nil, "species is required (50 characters max)")
+		return
+	}
+	if size == "" {
+		size = "standard"
+	}
+	if utf8.RuneCountInString(size) > 50 || utf8.RuneCountInString(name) > 100 {
+		SendJSON(w, http.StatusBadRequest, nil, "size or name is too long")
+		return
+	}
+	if name == "" {
+		name = species + " egg"
+		if size != "standard" {
+			name = size + " " + name
+		}
+		first, width := utf8.DecodeRuneInString(name)
+		name = string(unicode.ToUpper(first)) + name[width:]
+	}
+	if req.Nutrition != nil && !validNutrition(*req.Nutrition) {
+		SendJSON(w, http.StatusBadRequest, nil, "nutrition values must not be negative")
+		return
+	}
+
+	eggType, err := app.EggTypeModel.CreateEggType(r.Context(), userID, species, size, name, req.Nutrition)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEggType) {
+			SendJSON(w, http.StatusConflict, nil, "An egg type with that species and size already exists")
+			return
+		}
+		log.Printf("Error creating egg type: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to create egg type")
+		return
+	}
+
+	SendJSON(w, http.StatusCreated, eggType, "Egg type created successfully")
+}
+
+// setEggTypeNutritionHandler replaces the per-egg nutrition of one of the
+// user's custom egg types.
+func (app *Application) setEggTypeNutritionHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	eggTypeID, err := intURLParam(r, "id")
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid egg type ID")
+		return
+	}
+
+	var nutrition models.Nutrition
+	if err := json.NewDecoder(r.Body).Decode(&nutrition); err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid input")
+		return
+	}
+	if !validNutrition(nutrition) {
+		SendJSON(w, http.StatusBadRequest, nil, "nutrition values must not be negative")
+		return
+	}
+
+	err = app.EggTypeModel.SetNutrition(r.Context(), userID, eggTypeID, nutrition)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			SendJSON(w, http.StatusNotFound, nil, "Egg type not found")
+			return
+		}
+		log.Printf("Error setting egg type nutrition: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to update nutrition")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, nutrition, "Nutrition updated")
+}
+
+func (app *Application) deleteEggTypeHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	eggTypeID, err := intURLParam(r, "id")
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid egg type ID")
+		return
+	}
+
+	err = app.EggTypeModel.DeleteEggType(r.Context(), userID, eggTypeID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			SendJSON(w, http.StatusNotFound, nil, "Egg type not found")
+		case errors.Is(err, models.ErrEggTypeInUse):
+			SendJSON(w, http.StatusConflict, nil, "Egg type is used by existing entries or recipes")
+		default:
+			log.Printf("Error deleting egg type: %v", err)
+			SendJSON(w, http.StatusInternalServerError, nil, "Failed to delete egg type")
+		}
+		return
+	}
+
+	SendJSON(w, http.StatusOK, nil, "Egg type deleted")
+}
+
+// validNutrition reports whether every nutrient in n is non-negative.
+func validNutrition(n models.Nutrition) bool {
+	return n.ProteinG >= 0 && n.CaloriesKcal >= 0 && n.FatG >= 0 && n.CholesterolMg >= 0
+}
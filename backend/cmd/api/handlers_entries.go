@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+// maxEntriesPageSize caps how many entries one history request returns.
+const maxEntriesPageSize = 100
+
+// listEntriesHandler pages through the user's entry history. Pages are
+// keyed on eaten_at and id, so entries added while paging don't shift later
+// pages. Filters:
+//
+//   - from, to: local dates (YYYY-MM-DD) in the user's time zone, inclusive
+//   - min_amount, max_amount, egg_type_id
+//   - meal, tag
+//
+// order is desc (newest first, the default) or asc, and cursor is the
+// next_cursor of the previous page.
+func (app *Application) listEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit, err := readIntQuery(r, "limit", 20)
+	if err != nil || limit < 1 || limit > maxEntriesPageSize {
+		SendJSON(w, http.StatusBadRequest, nil, "limit must be between 1 and 100")
+		return
+	}
+
+	q := models.EntryQuery{
+		Limit: limit,
+		Filter: models.EntryFilter{
+			Meal: query.Get("meal"),
+			Tag:  strings.TrimSpace(query.Get("tag")),
+		},
+	}
+
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		q.Ascending = true
+	default:
+		SendJSON(w, http.StatusBadRequest, nil, "order must be asc or desc")
+		return
+	}
+
+	if token := query.Get("cursor"); token != "" {
+		q.After, err = models.ParseEntryCursor(token)
+		if err != nil {
+			SendJSON(w, http.StatusBadRequest, nil, "Invalid cursor")
+			return
+		}
+	}
+
+	if q.Filter.Meal != "" && !models.ValidMeal(q.Filter.Meal) {
+		SendJSON(w, http.StatusBadRequest, nil, "meal must be breakfast, lunch, dinner or snack")
+		return
+	}
+
+	for _, param := range []struct {
+		key  string
+		dest **int
+	}{
+		{"min_amount", &q.Filter.MinAmount},
+		{"max_amount", &q.Filter.MaxAmount},
+		{"egg_type_id", &q.Filter.EggTypeID},
+	} {
+		if query.Get(param.key) == "" {
+			continue
+		}
+		value, err := readIntQuery(r, param.key, 0)
+		if err != nil || value <= 0 {
+			SendJSON(w, http.StatusBadRequest, nil, param.key+" must be a positive integer")
+			return
+		}
+		*param.dest = &value
+	}
+	if q.Filter.MinAmount != nil && q.Filter.MaxAmount != nil && *q.Filter.MinAmount > *q.Filter.MaxAmount {
+		SendJSON(w, http.StatusBadRequest, nil, "min_amount must not be greater than max_amount")
+		return
+	}
+
+	// Dates are whole days in the user's time zone.
+	if query.Get("from") != "" || query.Get("to") != "" {
+		_, loc, err := app.userLocation(r, userID)
+		if err != nil {
+			log.Printf("Error loading user settings: %v", err)
+			SendJSON(w, http.StatusInternalServerError, nil, "Failed to retrieve entries")
+			return
+		}
+
+		if value := query.Get("from"); value != "" {
+			day, err := time.ParseInLocation("2006-01-02", value, loc)
+			if err != nil {
+				SendJSON(w, http.StatusBadRequest, nil, "from must be a date in YYYY-MM-DD format")
+				return
+			}
+			q.Filter.From = &day
+		}
+		if value := query.Get("to"); value != "" {
+			day, err := time.ParseInLocation("2006-01-02", value, loc)
+			if err != nil {
+				SendJSON(w, http.StatusBadRequest, nil, "to must be a date in YYYY-MM-DD format")
+				return
+			}
+			end := day.AddDate(0, 0, 1)
+			q.Filter.To = &end
+		}
+		if q.Filter.From != nil && q.Filter.To != nil && !q.Filter.From.Before(*q.Filter.To) {
+			SendJSON(w, http.StatusBadRequest, nil, "from must not be after to")
+			return
+		}
+	}
+
+	entries, next, err := app.EggModel.ListEntries(r.Context(), userID, q)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid cursor")
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching entries: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to retrieve entries")
+		return
+	}
+
+	var nextCursor *string
+	if next != nil {
+		token := next.String()
+		nextCursor = &token
+	}
+
+	SendPage(w, entries, nextCursor, "Entries retrieved successfully")
+}
+
+// validateEntryContext checks the meal, note and normalized tags of an entry
+// and returns a message describing the first problem, or "" if there is none.
+func validateEntryContext(meal, note *string, tags []string) string {
+	if meal != nil && *meal != "" && !models.ValidMeal(*meal) {
+		return "meal must be breakfast, lunch, dinner or snack"
+	}
+	if note != nil && utf8.RuneCountInString(*note) > models.MaxNoteLength {
+		return "note is too long (1000 characters max)"
+	}
+	if len(tags) > models.MaxTagsPerEntry {
+		return "An entry can have at most 10 tags"
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > models.MaxTagLength {
+			return "Tags are limited to 50 characters"
+		}
+	}
+	return ""
+}
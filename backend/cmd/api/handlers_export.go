@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+// exportFlushEvery is how many entries are written between flushes, so large
+// exports reach the client as they are produced.
+const exportFlushEvery = 500
+
+// exportWriteTimeout is how long the client has to take each flushed chunk
+// of an export before the connection is dropped.
+const exportWriteTimeout = time.Minute
+
+// exportCSVHeader names the columns of a CSV export.
+var exportCSVHeader = []string{
+	"id", "eaten_at", "amount", "egg_type_id", "egg_type", "status",
+	"meal", "note", "tags", "recipe_id", "created_at", "updated_at",
+}
+
+// entryWriter writes one export format. begin and end wrap the entries, and
+// flush pushes out anything the writer has buffered.
+type entryWriter struct {
+	contentType string
+	begin       func(w io.Writer) error
+	entry       func(w io.Writer, e *models.EggCount, first bool) error
+	flush       func() error
+	end         func(w io.Writer) error
+}
+
+// exportHandler streams every entry of the session user as csv, json or
+// ndjson (?format=, default csv). Entries are written as they are read, so
+// once streaming has started an error can only be logged and the response
+// cut short.
+func (app *Application) exportHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+	if !app.checkVerified(w, r, userID, restrictExport) {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	writer, ok := entryWriters()[format]
+	if !ok {
+		SendJSON(w, http.StatusBadRequest, nil, "format must be csv, json or ndjson")
+		return
+	}
+
+	// Name the file after the user's local date.
+	_, loc, err := app.userLocation(r, userID)
+	if err != nil {
+		log.Printf("Error loading user settings: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to export entries")
+		return
+	}
+	filename := fmt.Sprintf("eggcounter-export-%s.%s", time.Now().In(loc).Format("2006-01-02"), format)
+
+	// The deadline is pushed back at every flush, so a long export can run as
+	// long as it needs while a client that stops reading is cut off. Writers
+	// that can't take a deadline just go without.
+	rc := http.NewResponseController(w)
+	extendDeadline := func() {
+		_ = rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	}
+
+	begin := func() error {
+		extendDeadline()
+		w.Header().Set("Content-Type", writer.contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		return writer.begin(w)
+	}
+
+	started := false
+	count := 0
+	err = app.EggModel.EachEntry(r.Context(), userID, func(e *models.EggCount) error {
+		if !started {
+			started = true
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+
+		if err := writer.entry(w, e, count == 0); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			if err := writer.flush(); err != nil {
+				return err
+			}
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+			extendDeadline()
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			log.Printf("Error exporting entries: %v", err)
+			SendJSON(w, http.StatusInternalServerError, nil, "Failed to export entries")
+			return
+		}
+		log.Printf("Export for user %d stopped after %d entries: %v", userID, count, err)
+		return
+	}
+
+	// A user with no entries still gets a valid, empty file.
+	if !started {
+		if err := begin(); err != nil {
+			log.Printf("Error starting export: %v", err)
+			return
+		}
+	}
+	if err := writer.end(w); err != nil {
+		log.Printf("Error finishing export: %v", err)
+	}
+}
+
+// entryWriters returns the supported export formats by name. The CSV writer
+// keeps state, so each export gets fresh writers.
+func entryWriters() map[string]entryWriter {
+	var cw *csv.Writer
+
+	return map[string]entryWriter{
+		"csv": {
+			contentType: "text/csv; charset=utf-8",
+			begin: func(w io.Writer) error {
+				cw = csv.NewWriter(w)
+				return cw.Write(exportCSVHeader)
+			},
+			entry: func(w io.Writer, e *models.EggCount, first bool) error {
+				return cw.Write(exportCSVRecord(e))
+			},
+			flush: func() error {
+				cw.Flush()
+				return cw.Error()
+			},
+			end: func(w io.Writer) error {
+				cw.Flush()
+				return cw.Error()
+			},
+		},
+		"json": {
+			contentType: "application/json",
+			begin: func(w io.Writer) error {
+				_, err := io.WriteString(w, "[")
+				return err
+			},
+			entry: func(w io.Writer, e *models.EggCount, first bool) error {
+				if !first {
+					if _, err := io.WriteString(w, ","); err != nil {
+						return err
+					}
+				}
+				return json.NewEncoder(w).Encode(e)
+			},
+			flush: func() error { return nil },
+			end: func(w io.Writer) error {
+				_, err := io.WriteString(w, "]\n")
+				return err
+			},
+		},
+		"ndjson": {
+			contentType: "application/x-ndjson",
+			begin:       func(w io.Writer) error { return nil },
+			entry: func(w io.Writer, e *models.EggCount, first bool) error {
+				return json.NewEncoder(w).Encode(e)
+			},
+			flush: func() error { return nil },
+			end:   func(w io.Writer) error { return nil },
+		},
+	}
+}
+
+// exportCSVRecord flattens an entry into the columns of exportCSVHeader.
+// Times are RFC 3339 in UTC and tags are separated by semicolons. Text the
+// user typed goes through csvText.
+func exportCSVRecord(e *models.EggCount) []string {
+	optional := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+
+	recipeID, updatedAt := "", ""
+	if e.RecipeID != nil {
+		recipeID = strconv.Itoa(*e.RecipeID)
+	}
+	if e.UpdatedAt != nil {
+		updatedAt = e.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+
+	return []string{
+		strconv.Itoa(e.ID),
+		e.EatenAt.UTC().Format(time.RFC3339),
+		strconv.Itoa(e.Amount),
+		strconv.Itoa(e.EggTypeID),
+		csvText(e.EggType),
+		e.Status,
+		optional(e.Meal),
+		csvText(optional(e.Note)),
+		csvText(strings.Join(e.Tags, ";")),
+		recipeID,
+		e.CreatedAt.UTC().Format(time.RFC3339),
+		updatedAt,
+	}
+}
+
+// csvText stops spreadsheets from running user text as a formula by putting
+// an apostrophe in front of cells that start like one.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+func (app *Application) getGoalsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	progress, err := app.goalProgress(r, userID, time.Now())
+	if err != nil {
+		log.Printf("Error fetching goal progress: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to retrieve goals")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, progress, "Goals retrieved successfully")
+}
+
+// updateGoalsHandler replaces the user's goals; a null or omitted field
+// removes that goal.
+func (app *Application) updateGoalsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	var req models.Goals
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid input")
+		return
+	}
+
+	errors := map[string]string{}
+	for field, value := range map[string]*int{
+		"daily_max":  req.DailyMax,
+		"weekly_max": req.WeeklyMax,
+		"daily_min":  req.DailyMin,
+	} {
+		if value != nil && *value < 0 {
+			errors[field] = "Must not be negative"
+		}
+	}
+	if req.DailyMin != nil && req.DailyMax != nil && *req.DailyMin > *req.DailyMax {
+		errors["daily_min"] = "Must not be more than daily_max"
+	}
+	if req.DailyMax != nil && req.WeeklyMax != nil && *req.DailyMax > *req.WeeklyMax {
+		errors["daily_max"] = "Must not be more than weekly_max"
+	}
+
+	if len(errors) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":    nil,
+			"message": "Validation failed",
+			"errors":  errors,
+			"status":  http.StatusBadRequest,
+		})
+		return
+	}
+
+	if _, err := app.GoalModel.SaveGoals(r.Context(), userID, req); err != nil {
+		log.Printf("Error saving goals: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to update goals")
+		return
+	}
+
+	progress, err := app.goalProgress(r, userID, time.Now())
+	if err != nil {
+		log.Printf("Error fetching goal progress: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to retrieve goals")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, progress, "Goals updated successfully")
+}
+
+// goalProgress measures the user's intake on the day and in the week
+// containing at against their goals, using their own time zone and week start.
+func (app *Application) goalProgress(r *http.Request, userID int, at time.Time) (*models.GoalProgress, error) {
+	settings, loc, err := app.userLocation(r, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.GoalModel.GetProgress(r.Context(), userID, at, loc, time.Weekday(settings.WeekStart))
+}
+
+// newlyExceeded lists the limits exceeded in after that weren't in before.
+func newlyExceeded(before, after *models.GoalProgress) []string {
+	exceeded := []string{}
+	for _, limit := range after.Exceeded {
+		if !before.IsExceeded(limit) {
+			exceeded = append(exceeded, limit)
+		}
+	}
+	return exceeded
+}
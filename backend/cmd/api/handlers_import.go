@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+// Limits on a single import.
+const (
+	maxImportBytes = 10 << 20
+	maxImportRows  = 50000
+	// maxImportErrors caps how many row errors are reported back.
+	maxImportErrors = 200
+	// maxImportAmount caps the eggs in one row, well short of overflowing
+	// the amount column.
+	maxImportAmount = 1000
+)
+
+// importFields are the entry fields a CSV column can be mapped to. eaten_at
+// and amount are required.
+var importFields = []string{"eaten_at", "amount", "egg_type", "meal", "note", "tags"}
+
+// importRowError describes why one row of an import was rejected. Row 1 is
+// the first line after the header.
+type importRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// importReport summarises an import or dry run.
+type importReport struct {
+	DryRun    bool             `json:"dry_run"`
+	Rows      int              `json:"rows"`
+	Valid     int              `json:"valid"`
+	Imported  int              `json:"imported"`
+	Errors    []importRowError `json:"errors"`
+	Truncated bool             `json:"errors_truncated"`
+}
+
+// importHandler imports entries from an uploaded CSV file (multipart field
+// "file"). Optional form fields:
+//
+//   - mapping: JSON object from entry field to CSV header, e.g.
+//     {"eaten_at": "Date", "amount": "Eggs"}. Fields default to a column
+//     with the field's own name.
+//   - date_format: layout of eaten_at such as DD/MM/YYYY or YYYY-MM-DD HH:mm.
+//     Without one, RFC 3339 and YYYY-MM-DD are accepted. Times without a
+//     zone are in the user's time zone.
+//   - dry_run: when true, validate and report without importing.
+//
+// Nothing is imported unless every row is valid.
+func (app *Application) importHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+	if !app.checkVerified(w, r, userID, restrictImport) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	if err := r.ParseMultipartForm(maxImportBytes); err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Upload a CSV file of at most 10 MB in the file field")
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Upload a CSV file of at most 10 MB in the file field")
+		return
+	}
+	defer file.Close()
+
+	dryRun, _ := strconv.ParseBool(r.FormValue("dry_run"))
+
+	mapping := map[string]string{}
+	if value := r.FormValue("mapping"); value != "" {
+		if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+			SendJSON(w, http.StatusBadRequest, nil, "mapping must be a JSON object of field to column name")
+			return
+		}
+	}
+	for field := range mapping {
+		if !slices.Contains(importFields, field) {
+			SendJSON(w, http.StatusBadRequest, nil, "Unknown field in mapping: "+field)
+			return
+		}
+	}
+
+	_, loc, err := app.userLocation(r, userID)
+	if err != nil {
+		log.Printf("Error loading user settings: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to import entries")
+		return
+	}
+	layouts := []string{time.RFC3339, "2006-01-02"}
+	if value := r.FormValue("date_format"); value != "" {
+		layouts = []string{dateLayoutFromFormat(value)}
+	}
+
+	types, err := app.EggTypeModel.ListEggTypes(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching egg types: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to import entries")
+		return
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "The file is empty or not valid CSV")
+		return
+	}
+	columns, err := importColumns(header, mapping)
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid mapping: "+err.Error())
+		return
+	}
+
+	parser := importParser{columns: columns, layouts: layouts, loc: loc, types: eggTypeLookup(types)}
+	report := importReport{DryRun: dryRun, Errors: []importRowError{}}
+	var rows []models.ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		report.Rows++
+		if report.Rows > maxImportRows {
+			SendJSON(w, http.StatusRequestEntityTooLarge, nil, fmt.Sprintf("Imports are limited to %d rows", maxImportRows))
+			return
+		}
+
+		var row models.ImportRow
+		var rowErrors []importRowError
+		if err != nil {
+			rowErrors = []importRowError{{Message: "Malformed CSV: " + err.Error()}}
+		} else {
+			row, rowErrors = parser.parse(record)
+		}
+
+		if len(rowErrors) == 0 {
+			report.Valid++
+			rows = append(rows, row)
+			continue
+		}
+		for _, rowError := range rowErrors {
+			if len(report.Errors) == maxImportErrors {
+				report.Truncated = true
+				break
+			}
+			rowError.Row = report.Rows
+			report.Errors = append(report.Errors, rowError)
+		}
+	}
+
+	if dryRun {
+		SendJSON(w, http.StatusOK, report, "Dry run complete, nothing was imported")
+		return
+	}
+	if len(report.Errors) > 0 {
+		SendJSON(w, http.StatusUnprocessableEntity, report, "Some rows are invalid, nothing was imported")
+		return
+	}
+
+	report.Imported, err = app.EggModel.ImportEntries(r.Context(), userID, rows)
+	if err != nil {
+		log.Printf("Error importing entries: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to import entries")
+		return
+	}
+
+	SendJSON(w, http.StatusCreated, report, fmt.Sprintf("Imported %d entries", report.Imported))
+}
+
+// importColumns maps each entry field to its column index in header, or -1
+// when an optional field has no column. Header names match case-insensitively.
+func importColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	// Spreadsheets often save a byte order mark before the first header.
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	columns := map[string]int{}
+	for _, field := range importFields {
+		name, mapped := mapping[field]
+		if !mapped {
+			name = field
+		}
+
+		i, ok := index[strings.ToLower(strings.TrimSpace(name))]
+		switch {
+		case ok:
+			columns[field] = i
+		case mapped || field == "eaten_at" || field == "amount":
+			return nil, fmt.Errorf("column %q for %s is not in the file", name, field)
+		default:
+			columns[field] = -1
+		}
+	}
+	return columns, nil
+}
+
+// importParser validates CSV records and turns them into ImportRows.
+type importParser struct {
+	columns map[string]int
+	layouts []string
+	loc     *time.Location
+	types   map[string]int
+}
+
+func (p importParser) parse(record []string) (models.ImportRow, []importRowError) {
+	var row models.ImportRow
+	var rowErrors []importRowError
+	fail := func(field, message string) {
+		rowErrors = append(rowErrors, importRowError{Field: field, Message: message})
+	}
+	value := func(field string) string {
+		i := p.columns[field]
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	eatenAt, err := parseImportTime(value("eaten_at"), p.layouts, p.loc)
+	switch {
+	case err != nil:
+		fail("eaten_at", "Not a valid date: "+strconv.Quote(value("eaten_at")))
+	case eatenAt.After(time.Now().Add(models.MaxClockSkew)):
+		fail("eaten_at", "Cannot be in the future")
+	}
+	row.EatenAt = eatenAt
+
+	amount, err := strconv.Atoi(value("amount"))
+	if err != nil || amount <= 0 || amount > maxImportAmount {
+		fail("amount", fmt.Sprintf("Must be a whole number between 1 and %d", maxImportAmount))
+	}
+	row.Amount = amount
+
+	if name := unescapeCSVText(value("egg_type")); name != "" {
+		eggTypeID, ok := p.types[strings.ToLower(name)]
+		if !ok {
+			fail("egg_type", "Unknown egg type: "+strconv.Quote(name))
+		}
+		row.EggTypeID = &eggTypeID
+	}
+
+	row.Meal = strings.ToLower(value("meal"))
+	row.Note = unescapeCSVText(value("note"))
+	if tags := unescapeCSVText(value("tags")); tags != "" {
+		row.Tags = models.NormalizeTags(strings.Split(tags, ";"))
+	}
+	if msg := validateEntryContext(&row.Meal, &row.Note, row.Tags); msg != "" {
+		fail("", msg)
+	}
+
+	return row, rowErrors
+}
+
+// unescapeCSVText undoes csvText, so an export imports back unchanged.
+func unescapeCSVText(s string) string {
+	if len(s) > 1 && s[0] == '\'' && csvText(s[1:]) != s[1:] {
+		return s[1:]
+	}
+	return s
+}
+
+// eggTypeLookup indexes egg types by lower-case name, slug and id so an
+// import can refer to them by any of these.
+func eggTypeLookup(types []models.EggType) map[string]int {
+	lookup := map[string]int{}
+	for _, t := range types {
+		lookup[strings.ToLower(t.Name)] = t.ID
+		lookup[strconv.Itoa(t.ID)] = t.ID
+		if t.Slug != nil {
+			lookup[*t.Slug] = t.ID
+		}
+	}
+	return lookup
+}
+
+// parseImportTime parses value with the first layout that fits. Layouts
+// without a zone are read in loc.
+func parseImportTime(value string, layouts []string, loc *time.Location) (time.Time, error) {
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// dateLayoutFromFormat converts a spreadsheet-style date format such as
+// DD/MM/YYYY HH:mm into a Go layout. Formats that are already Go layouts are
+// returned unchanged.
+func dateLayoutFromFormat(format string) string {
+	if strings.Contains(format, "2006") {
+		return format
+	}
+	return strings.NewReplacer(
+		"YYYY", "2006",
+		"YY", "06",
+		"MM", "01",
+		"DD", "02",
+		"HH", "15",
+		"mm", "04",
+		"ss", "05",
+	).Replace(format)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+func (app *Application) nutritionSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	statsRange, ok := app.readStatsRange(w, r, userID)
+	if !ok {
+		return
+	}
+
+	summary, err := app.NutritionModel.GetSummary(r.Context(), userID, statsRange)
+	if err != nil {
+		log.Printf("Error computing nutrition summary: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to compute nutrition summary")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, summary, "Nutrition summary retrieved successfully")
+}
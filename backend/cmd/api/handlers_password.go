@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iankencruz/eggcounter/backend/internal/mailer"
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+// Password reset links work for passwordResetTTL. Like verification emails,
+// they're sent at most once every passwordResetResendDelay and
+// passwordResetDailyLimit times a day per account.
+const (
+	passwordResetTTL         = time.Hour
+	passwordResetResendDelay = time.Minute
+	passwordResetDailyLimit  = 5
+)
+
+// passwordResetIPThrottle limits how many reset emails one client can ask
+// for, whichever addresses it names. Every request counts, so after a burst
+// the client has to wait before asking again.
+var passwordResetIPThrottle = models.ThrottlePolicy{
+	FreeAttempts: 10,
+	BaseDelay:    time.Second,
+	MaxDelay:     time.Minute,
+	LockoutAfter: 30,
+	Lockout:      time.Hour,
+	Window:       time.Hour,
+}
+
+func passwordResetThrottleKey(r *http.Request) string {
+	return "reset-ip:" + clientIP(r)
+}
+
+// forgotPasswordHandler emails a password reset link to the address given,
+// if it belongs to an account. The response is the same either way, and the
+// lookup happens after responding so its timing doesn't tell either. Clients
+// asking too often get a 429, which says nothing about the address.
+func (app *Application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		SendJSON(w, http.StatusBadRequest, nil, "Email is required")
+		return
+	}
+
+	key := passwordResetThrottleKey(r)
+	until, err := app.ThrottleModel.BlockedUntil(r.Context(), []string{key})
+	if err != nil {
+		log.Printf("Error checking password reset throttle: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to send reset link")
+		return
+	}
+	if !until.IsZero() {
+		seconds := int(math.Ceil(time.Until(until).Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		SendJSON(w, http.StatusTooManyRequests, nil, "Too many reset requests. Please try again later.")
+		return
+	}
+	if _, _, err := app.ThrottleModel.RecordFailure(r.Context(), key, passwordResetIPThrottle); err != nil {
+		log.Printf("Error recording password reset request: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to send reset link")
+		return
+	}
+
+	go app.sendPasswordReset(strings.TrimSpace(req.Email))
+
+	SendJSON(w, http.StatusOK, nil, "If an account uses that email, a reset link is on its way")
+}
+
+// sendPasswordReset creates a reset token for the account with the email, if
+// any, and mails the link. Requests over the account's limit are dropped
+// silently, since the response mustn't differ. It runs outside the request,
+// so errors are logged.
+func (app *Application) sendPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	user, err := app.UserModel.GetUserByEmail(ctx, email)
+	if errors.Is(err, models.ErrNoRecord) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up user for password reset: %v", err)
+		return
+	}
+
+	token, tokenHash, err := newToken()
+	if err != nil {
+		log.Printf("Error generating password reset token: %v", err)
+		return
+	}
+	limit := models.TokenLimit{ResendDelay: passwordResetResendDelay, Daily: passwordResetDailyLimit}
+	err = app.TokenModel.CreateTokenWithinLimit(ctx, user.ID, models.TokenPasswordReset, tokenHash, passwordResetTTL, limit)
+	if errors.Is(err, models.ErrTokenLimit) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving password reset token: %v", err)
+		return
+	}
+
+	link := app.BaseURL + "/reset-password?token=" + url.QueryEscape(token)
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Egg Counter password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password for your account. If it was you, open this link within an hour to choose a new one:\n\n%s\n\nIf it wasn't, you can ignore this email.\n",
+			user.Username, link,
+		),
+	}
+	app.sendMail(msg)
+}
+
+// resetPasswordHandler sets a new password using a token from a reset email.
+// Every session for the account is logged out.
+func (app *Application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid request body")
+		return
+	}
+
+	if errs := validateResetRequest(req.Token, req.Password); len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":    nil,
+			"message": "Validation failed",
+			"errors":  errs,
+			"status":  http.StatusBadRequest,
+		})
+		return
+	}
+
+	if _, err := app.UserModel.ResetPassword(r.Context(), hashToken(req.Token), req.Password); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			SendJSON(w, http.StatusBadRequest, nil, "This reset link is invalid or has expired")
+			return
+		}
+		log.Printf("Error resetting password: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to reset password")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, nil, "Password updated. Please log in with your new password.")
+}
+
+func validateResetRequest(token, password string) map[string]string {
+	errors := map[string]string{}
+	if token == "" {
+		errors["token"] = "Token is required"
+	}
+	if msg := validatePassword(password); msg != "" {
+		errors["password"] = msg
+	}
+	return errors
+}
+
+// validatePassword returns why password can't be used, or "" if it can.
+// Registration, resets and password changes all go through it.
+func validatePassword(password string) string {
+	if password == "" {
+		return "Password is required"
+	}
+	if len(password) < 8 {
+		return "Password must be at least 8 characters"
+	}
+	return ""
+}
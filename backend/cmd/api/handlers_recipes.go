@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+// maxRecipeServings caps the servings logged from a recipe at once, and
+// maxRecipeEggAmount the eggs of one type in a serving, so a logged amount
+// stays small.
+const (
+	maxRecipeServings  = 20
+	maxRecipeEggAmount = 50
+)
+
+func (app *Application) listRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	recipes, err := app.RecipeModel.ListRecipes(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching recipes: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to retrieve recipes")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, recipes, "Recipes retrieved successfully")
+}
+
+func (app *Application) getRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	recipeID, err := intURLParam(r, "id")
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid recipe ID")
+		return
+	}
+
+	recipe, err := app.RecipeModel.GetRecipe(r.Context(), userID, recipeID)
+	if err != nil {
+		sendRecipeError(w, err, "retrieve recipe")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, recipe, "Recipe retrieved successfully")
+}
+
+func (app *Application) createRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	in, ok := readRecipeInput(w, r)
+	if !ok {
+		return
+	}
+
+	recipe, err := app.RecipeModel.CreateRecipe(r.Context(), userID, in)
+	if err != nil {
+		sendRecipeError(w, err, "create recipe")
+		return
+	}
+
+	SendJSON(w, http.StatusCreated, recipe, "Recipe created successfully")
+}
+
+func (app *Application) updateRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	recipeID, err := intURLParam(r, "id")
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid recipe ID")
+		return
+	}
+
+	in, ok := readRecipeInput(w, r)
+	if !ok {
+		return
+	}
+
+	recipe, err := app.RecipeModel.UpdateRecipe(r.Context(), userID, recipeID, in)
+	if err != nil {
+		sendRecipeError(w, err, "update recipe")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, recipe, "Recipe updated successfully")
+}
+
+func (app *Application) deleteRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	recipeID, err := intURLParam(r, "id")
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid recipe ID")
+		return
+	}
+
+	if err := app.RecipeModel.DeleteRecipe(r.Context(), userID, recipeID); err != nil {
+		sendRecipeError(w, err, "delete recipe")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, nil, "Recipe deleted")
+}
+
+func (app *Application) recipeUsageHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	usage, err := app.RecipeModel.GetRecipeUsage(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching recipe usage: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to retrieve recipe usage")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, usage, "Recipe usage retrieved successfully")
+}
+
+// logRecipeHandler logs servings of a recipe, expanding it into one entry per
+// egg type. Like addEggCountHandler it reports any limits the new entries
+// pushed the user over.
+func (app *Application) logRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	var req struct {
+		RecipeID int        `json:"recipe_id"`
+		Servings int        `json:"servings"`
+		EatenAt  *time.Time `json:"eaten_at"`
+		Meal     string     `json:"meal"`
+		Note     string     `json:"note"`
+		Tags     []string   `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RecipeID <= 0 {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid input")
+		return
+	}
+	if req.Servings == 0 {
+		req.Servings = 1
+	}
+	if req.Servings < 0 || req.Servings > maxRecipeServings {
+		SendJSON(w, http.StatusBadRequest, nil, "servings must be between 1 and 20")
+		return
+	}
+
+	req.Note = strings.TrimSpace(req.Note)
+	req.Tags = models.NormalizeTags(req.Tags)
+	if msg := validateEntryContext(&req.Meal, &req.Note, req.Tags); msg != "" {
+		SendJSON(w, http.StatusBadRequest, nil, msg)
+		return
+	}
+
+	serving := models.RecipeServing{
+		Servings: req.Servings,
+		Meal:     req.Meal,
+		Note:     req.Note,
+		Tags:     req.Tags,
+	}
+	if req.EatenAt != nil {
+		serving.EatenAt = *req.EatenAt
+	}
+
+	eatenAt := time.Now()
+	if req.EatenAt != nil {
+		eatenAt = *req.EatenAt
+	}
+	before, err := app.goalProgress(r, userID, eatenAt)
+	if err != nil {
+		log.Printf("Error fetching goal progress: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to log recipe")
+		return
+	}
+
+	recipeLog, err := app.RecipeModel.LogRecipe(r.Context(), userID, req.RecipeID, serving)
+	if err != nil {
+		if errors.Is(err, models.ErrEatenAtInFuture) {
+			SendJSON(w, http.StatusBadRequest, nil, "eaten_at cannot be in the future")
+			return
+		}
+		sendRecipeError(w, err, "log recipe")
+		return
+	}
+
+	if err := app.NutritionModel.Annotate(r.Context(), recipeLog.Entries); err != nil {
+		log.Printf("Error loading nutrition: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Recipe logged, but failed to load its nutrition")
+		return
+	}
+
+	after, err := app.goalProgress(r, userID, eatenAt)
+	if err != nil {
+		log.Printf("Error fetching goal progress: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Recipe logged, but failed to load goal progress")
+		return
+	}
+
+	limitExceeded := newlyExceeded(before, after)
+	data := map[string]interface{}{
+		"recipe_log":     recipeLog,
+		"progress":       after,
+		"limit_exceeded": limitExceeded,
+	}
+
+	message := "Recipe logged successfully"
+	if len(limitExceeded) > 0 {
+		message = "Recipe logged, but you are now over your limit"
+	}
+	SendJSON(w, http.StatusCreated, data, message)
+}
+
+// readRecipeInput decodes and validates a recipe from the request body. On
+// failure it writes the error response and returns false.
+func readRecipeInput(w http.ResponseWriter, r *http.Request) (models.RecipeInput, bool) {
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Eggs        []struct {
+			EggTypeID int `json:"egg_type_id"`
+			Amount    int `json:"amount"`
+		} `json:"eggs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid input")
+		return models.RecipeInput{}, false
+	}
+
+	in := models.RecipeInput{
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+	}
+
+	errors := map[string]string{}
+	if in.Name == "" || utf8.RuneCountInString(in.Name) > 100 {
+		errors["name"] = "Name is required (100 characters max)"
+	}
+	if utf8.RuneCountInString(in.Description) > 1000 {
+		errors["description"] = "Description is too long (1000 characters max)"
+	}
+	if len(req.Eggs) == 0 {
+		errors["eggs"] = "A recipe needs at least one egg"
+	}
+	seen := map[int]bool{}
+	for _, e := range req.Eggs {
+		switch {
+		case e.EggTypeID <= 0 || e.Amount <= 0 || e.Amount > maxRecipeEggAmount:
+			errors["eggs"] = fmt.Sprintf("Each egg needs an egg_type_id and an amount between 1 and %d", maxRecipeEggAmount)
+		case seen[e.EggTypeID]:
+			errors["eggs"] = "Each egg type can only be listed once"
+		}
+		seen[e.EggTypeID] = true
+		in.Eggs = append(in.Eggs, models.RecipeEggs{EggTypeID: e.EggTypeID, Amount: e.Amount})
+	}
+
+	if len(errors) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":    nil,
+			"message": "Validation failed",
+			"errors":  errors,
+			"status":  http.StatusBadRequest,
+		})
+		return models.RecipeInput{}, false
+	}
+
+	return in, true
+}
+
+// sendRecipeError maps RecipeModel errors to HTTP responses. action describes
+// what failed, e.g. "update recipe".
+func sendRecipeError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		SendJSON(w, http.StatusNotFound, nil, "Recipe not found")
+	case errors.Is(err, models.ErrDuplicateRecipe):
+		SendJSON(w, http.StatusConflict, nil, "You already have a recipe with that name")
+	case errors.Is(err, models.ErrUnknownEggType):
+		SendJSON(w, http.StatusBadRequest, nil, "Unknown egg type")
+	default:
+		log.Printf("Failed to %s: %v", action, err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to "+action)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+	"golang.org/x/text/language"
+)
+
+func (app *Application) getSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	settings, err := app.SettingsModel.GetSettings(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching settings: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to retrieve settings")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, settings, "Settings retrieved successfully")
+}
+
+func (app *Application) updateSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	// Omitted fields keep their current value.
+	var req struct {
+		TimeZone  *string `json:"time_zone"`
+		WeekStart *int    `json:"week_start"`
+		Locale    *string `json:"locale"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid input")
+		return
+	}
+
+	settings, err := app.SettingsModel.GetSettings(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching settings: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to update settings")
+		return
+	}
+
+	errors := map[string]string{}
+	if req.TimeZone != nil {
+		if _, err := time.LoadLocation(*req.TimeZone); err != nil || *req.TimeZone == "" || *req.TimeZone == "Local" {
+			errors["time_zone"] = "Must be an IANA time zone such as Australia/Sydney"
+		} else if known, err := app.SettingsModel.IsKnownTimeZone(r.Context(), *req.TimeZone); err != nil {
+			log.Printf("Error checking time zone: %v", err)
+			SendJSON(w, http.StatusInternalServerError, nil, "Failed to update settings")
+			return
+		} else if !known {
+			errors["time_zone"] = "This time zone isn't supported by the server"
+		}
+		settings.TimeZone = *req.TimeZone
+	}
+	if req.WeekStart != nil {
+		if *req.WeekStart < int(time.Sunday) || *req.WeekStart > int(time.Saturday) {
+			errors["week_start"] = "Must be between 0 (Sunday) and 6 (Saturday)"
+		}
+		settings.WeekStart = *req.WeekStart
+	}
+	if req.Locale != nil {
+		tag, err := language.Parse(*req.Locale)
+		if err != nil {
+			errors["locale"] = "Must be a language tag such as en-AU"
+		}
+		settings.Locale = tag.String()
+	}
+
+	if len(errors) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":    nil,
+			"message": "Validation failed",
+			"errors":  errors,
+			"status":  http.StatusBadRequest,
+		})
+		return
+	}
+
+	saved, err := app.SettingsModel.SaveSettings(r.Context(), userID, *settings)
+	if err != nil {
+		log.Printf("Error saving settings: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to update settings")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, saved, "Settings updated successfully")
+}
+
+// userLocation loads the user's settings and time zone, which decide where
+// their days and weeks begin.
+func (app *Application) userLocation(r *http.Request, userID int) (*models.Settings, *time.Location, error) {
+	settings, err := app.SettingsModel.GetSettings(r.Context(), userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	loc, err := settings.Location()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return settings, loc, nil
+}
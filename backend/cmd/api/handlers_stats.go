@@ -0,0 +1,108 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+// maxStatsDays caps the span of a stats request.
+const maxStatsDays = 3 * 366
+
+func (app *Application) statsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	statsRange, ok := app.readStatsRange(w, r, userID)
+	if !ok {
+		return
+	}
+
+	stats, err := app.EggModel.GetStats(r.Context(), userID, statsRange)
+	if err != nil {
+		log.Printf("Error computing stats: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to compute statistics")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, stats, "Statistics retrieved successfully")
+}
+
+// readStatsRange parses the bucket, from and to query parameters shared by
+// the statistics endpoints. On failure it writes the error response and
+// returns false.
+func (app *Application) readStatsRange(w http.ResponseWriter, r *http.Request, userID int) (models.StatsRange, bool) {
+	query := r.URL.Query()
+
+	bucket := query.Get("bucket")
+	if bucket == "" {
+		bucket = models.BucketDay
+	}
+	if bucket != models.BucketDay && bucket != models.BucketWeek && bucket != models.BucketMonth {
+		SendJSON(w, http.StatusBadRequest, nil, "bucket must be day, week or month")
+		return models.StatsRange{}, false
+	}
+
+	// Days and weeks are bucketed according to the user's settings.
+	settings, loc, err := app.userLocation(r, userID)
+	if err != nil {
+		log.Printf("Error loading user settings: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to compute statistics")
+		return models.StatsRange{}, false
+	}
+	weekStart := time.Weekday(settings.WeekStart)
+
+	// Default to the last 30 days, or the last 12 whole weeks or months
+	// including the current one.
+	now := time.Now().In(loc)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if value := query.Get("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			SendJSON(w, http.StatusBadRequest, nil, "to must be a date in YYYY-MM-DD format")
+			return models.StatsRange{}, false
+		}
+		to = parsed
+	}
+
+	var from time.Time
+	switch bucket {
+	case models.BucketWeek:
+		offset := (int(to.Weekday()) - int(weekStart) + 7) % 7
+		from = to.AddDate(0, 0, -offset-7*11)
+	case models.BucketMonth:
+		from = time.Date(to.Year(), to.Month()-11, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		from = to.AddDate(0, 0, -29)
+	}
+	if value := query.Get("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			SendJSON(w, http.StatusBadRequest, nil, "from must be a date in YYYY-MM-DD format")
+			return models.StatsRange{}, false
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		SendJSON(w, http.StatusBadRequest, nil, "from must not be after to")
+		return models.StatsRange{}, false
+	}
+	if to.Sub(from) > maxStatsDays*24*time.Hour {
+		SendJSON(w, http.StatusBadRequest, nil, "Date range is too long")
+		return models.StatsRange{}, false
+	}
+
+	return models.StatsRange{
+		Bucket:    bucket,
+		From:      from,
+		To:        to,
+		Location:  loc,
+		WeekStart: weekStart,
+	}, true
+}
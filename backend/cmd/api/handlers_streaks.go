@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+// maxStreakThreshold bounds the ?min= and ?limit= a request can ask for.
+const maxStreakThreshold = 1000
+
+// streaksHandler reports the user's streaks. ?min= sets the eggs needed for a
+// day to count towards the minimum streak and ?limit= the daily limit for the
+// under-limit streak; both default to the user's goals. Only the goal
+// thresholds are cached, so other values are computed afresh each time.
+func (app *Application) streaksHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	goals, err := app.GoalModel.GetGoals(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching goals: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to compute streaks")
+		return
+	}
+	defaultMinimum, defaultLimit := streakThresholds(goals)
+
+	minimum, err := readIntQuery(r, "min", defaultMinimum)
+	if err != nil || minimum < 1 || (minimum != defaultMinimum && minimum > maxStreakThreshold) {
+		SendJSON(w, http.StatusBadRequest, nil, fmt.Sprintf("min must be between 1 and %d", maxStreakThreshold))
+		return
+	}
+	limit, err := readIntQuery(r, "limit", defaultLimit)
+	if err != nil || limit < 0 || (limit != defaultLimit && limit > maxStreakThreshold) {
+		SendJSON(w, http.StatusBadRequest, nil, fmt.Sprintf("limit must be between 0 and %d", maxStreakThreshold))
+		return
+	}
+
+	goalThresholds := minimum == defaultMinimum && limit == defaultLimit
+	streaks, err := app.userStreaks(r, userID, minimum, limit, goalThresholds)
+	if err != nil {
+		log.Printf("Error computing streaks: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to compute streaks")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, streaks, "Streaks retrieved successfully")
+}
+
+// userStreaks computes the user's streaks in their own time zone. cache
+// should only be set for the thresholds from the user's goals.
+func (app *Application) userStreaks(r *http.Request, userID, minimum, limit int, cache bool) (*models.Streaks, error) {
+	_, loc, err := app.userLocation(r, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.StreakModel.GetStreaks(r.Context(), userID, minimum, limit, loc, cache)
+}
+
+// streakThresholds picks streak thresholds from the user's daily goals,
+// falling back to the defaults for goals they haven't set.
+func streakThresholds(goals *models.Goals) (minimum, limit int) {
+	minimum, limit = models.DefaultStreakMinimum, models.DefaultDailyLimit
+	if goals.DailyMin != nil && *goals.DailyMin > 0 {
+		minimum = *goals.DailyMin
+	}
+	if goals.DailyMax != nil {
+		limit = *goals.DailyMax
+	}
+	return minimum, limit
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+func (app *Application) listTagsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	tags, err := app.TagModel.ListTags(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching tags: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to retrieve tags")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, tags, "Tags retrieved successfully")
+}
+
+func (app *Application) deleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	tagID, err := intURLParam(r, "id")
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid tag ID")
+		return
+	}
+
+	err = app.TagModel.DeleteTag(r.Context(), userID, tagID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			SendJSON(w, http.StatusNotFound, nil, "Tag not found")
+			return
+		}
+		log.Printf("Error deleting tag: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to delete tag")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, nil, "Tag deleted")
+}
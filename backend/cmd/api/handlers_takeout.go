@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+// createTakeoutHandler queues an archive of all the user's data. The
+// response carries the download link, which starts working once the takeout
+// is ready and stops when it expires.
+func (app *Application) createTakeoutHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+	if !app.checkVerified(w, r, userID, restrictTakeout) {
+		return
+	}
+
+	token, tokenHash, err := newToken()
+	if err != nil {
+		log.Printf("Error generating takeout token: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to start takeout")
+		return
+	}
+
+	takeout, err := app.TakeoutModel.CreateTakeout(r.Context(), userID, tokenHash)
+	if err != nil {
+		if errors.Is(err, models.ErrTakeoutInProgress) {
+			SendJSON(w, http.StatusConflict, nil, "A takeout is already being prepared")
+			return
+		}
+		log.Printf("Error creating takeout: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to start takeout")
+		return
+	}
+	app.wakeJobs()
+
+	data := map[string]interface{}{
+		"takeout":      takeout,
+		"download_url": "/api/me/takeout/download?token=" + url.QueryEscape(token),
+	}
+	SendJSON(w, http.StatusAccepted, data, "Takeout started. Check its status to see when it is ready.")
+}
+
+func (app *Application) getTakeoutHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	takeoutID, err := intURLParam(r, "id")
+	if err != nil {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid takeout ID")
+		return
+	}
+
+	takeout, err := app.TakeoutModel.GetTakeout(r.Context(), userID, takeoutID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			SendJSON(w, http.StatusNotFound, nil, "Takeout not found")
+			return
+		}
+		log.Printf("Error fetching takeout: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to retrieve takeout")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, takeout, "Takeout retrieved successfully")
+}
+
+// downloadTakeoutHandler serves a ready takeout archive. The link only works
+// for the user who requested it, until it expires.
+func (app *Application) downloadTakeoutHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		SendJSON(w, http.StatusBadRequest, nil, "Missing token")
+		return
+	}
+
+	takeout, archive, err := app.TakeoutModel.GetArchive(r.Context(), userID, hashToken(token))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			SendJSON(w, http.StatusNotFound, nil, "This link is invalid, expired or not ready yet")
+			return
+		}
+		log.Printf("Error fetching takeout archive: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to download takeout")
+		return
+	}
+
+	filename := fmt.Sprintf("eggcounter-takeout-%s.zip", takeout.CompletedAt.Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", fmt.Sprint(len(archive)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
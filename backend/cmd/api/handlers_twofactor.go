@@ -0,0 +1,365 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+	"github.com/iankencruz/eggcounter/backend/internal/totp"
+	"github.com/skip2/go-qrcode"
+)
+
+// totpIssuer names the account in authenticator apps.
+const totpIssuer = "Egg Counter"
+
+// Two-factor login: after the password, the session holds the user id under
+// pendingTwoFactorKey rather than "userID" until a code is entered.
+const (
+	pendingTwoFactorKey        = "twoFactorUserID"
+	pendingTwoFactorSinceKey   = "twoFactorSince"
+	pendingTwoFactorAttemptKey = "twoFactorAttempts"
+
+	twoFactorLoginWindow = 5 * time.Minute
+	twoFactorMaxAttempts = 5
+)
+
+// Recovery codes are recoveryCodeGroups groups of recoveryCodeGroupChars
+// base32 characters, 100 random bits each. That is too many to guess or to
+// brute force from a leaked hash, so a fast unsalted hash is enough.
+const (
+	recoveryCodeCount      = 10
+	recoveryCodeGroups     = 4
+	recoveryCodeGroupChars = 5
+)
+
+// enrollTwoFactorHandler starts two-factor enrollment. It returns a new
+// secret as an otpauth:// URI and a QR code PNG for the authenticator app;
+// two-factor authentication turns on once a code is confirmed.
+func (app *Application) enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
+		SendJSON(w, http.StatusBadRequest, nil, "Confirm with your password")
+		return
+	}
+
+	if err := app.UserModel.CheckPassword(r.Context(), userID, req.Password); err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			SendJSON(w, http.StatusForbidden, nil, "Incorrect password")
+			return
+		}
+		log.Printf("Error checking password: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to start two-factor enrollment")
+		return
+	}
+
+	user, err := app.UserModel.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching user: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to start two-factor enrollment")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Printf("Error generating TOTP secret: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to start two-factor enrollment")
+		return
+	}
+
+	if err := app.TwoFactorModel.BeginEnrollment(r.Context(), userID, secret); err != nil {
+		if errors.Is(err, models.ErrTwoFactorEnabled) {
+			SendJSON(w, http.StatusConflict, nil, "Two-factor authentication is already enabled")
+			return
+		}
+		log.Printf("Error starting two-factor enrollment: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to start two-factor enrollment")
+		return
+	}
+
+	uri := totp.URI(totpIssuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		log.Printf("Error encoding QR code: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to start two-factor enrollment")
+		return
+	}
+
+	data := map[string]interface{}{
+		"secret":      secret,
+		"otpauth_uri": uri,
+		"qr_png":      base64.StdEncoding.EncodeToString(png),
+	}
+	SendJSON(w, http.StatusOK, data, "Scan the QR code, then confirm with a code from your app")
+}
+
+// confirmTwoFactorHandler turns on two-factor authentication with the first
+// code from the authenticator app. The response holds the recovery codes,
+// which are only ever shown here.
+func (app *Application) confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		SendJSON(w, http.StatusBadRequest, nil, "A code is required")
+		return
+	}
+
+	secret, err := app.TwoFactorModel.GetSecret(r.Context(), userID, false)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			SendJSON(w, http.StatusConflict, nil, "Start two-factor enrollment first")
+			return
+		}
+		log.Printf("Error fetching TOTP secret: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to enable two-factor authentication")
+		return
+	}
+
+	step, ok := totp.Validate(secret, req.Code, time.Now())
+	if !ok {
+		SendJSON(w, http.StatusBadRequest, nil, "Invalid code")
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		log.Printf("Error generating recovery codes: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to enable two-factor authentication")
+		return
+	}
+
+	if err := app.TwoFactorModel.ConfirmEnrollment(r.Context(), userID, secret, step, hashes, auditSource(r)); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			SendJSON(w, http.StatusConflict, nil, "Start two-factor enrollment first")
+			return
+		}
+		log.Printf("Error confirming two-factor enrollment: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to enable two-factor authentication")
+		return
+	}
+
+	data := map[string]interface{}{
+		"recovery_codes": codes,
+	}
+	SendJSON(w, http.StatusOK, data, "Two-factor authentication enabled. Store these recovery codes somewhere safe.")
+}
+
+// disableTwoFactorHandler turns off two-factor authentication. It needs the
+// password and a current code.
+func (app *Application) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" || req.Code == "" {
+		SendJSON(w, http.StatusBadRequest, nil, "Confirm with your password and a code")
+		return
+	}
+
+	if err := app.UserModel.CheckPassword(r.Context(), userID, req.Password); err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			SendJSON(w, http.StatusForbidden, nil, "Incorrect password")
+			return
+		}
+		log.Printf("Error checking password: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to disable two-factor authentication")
+		return
+	}
+
+	if err := app.checkTwoFactorCode(r, userID, req.Code); err != nil {
+		sendTwoFactorError(w, err, "disable two-factor authentication")
+		return
+	}
+
+	if err := app.TwoFactorModel.Disable(r.Context(), userID, auditSource(r)); err != nil {
+		sendTwoFactorError(w, err, "disable two-factor authentication")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, nil, "Two-factor authentication disabled")
+}
+
+// loginTwoFactorHandler completes a login whose password was accepted, using
+// a code from the authenticator app or a recovery code.
+func (app *Application) loginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), pendingTwoFactorKey)
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "No login is waiting for a code. Please log in.")
+		return
+	}
+
+	since := time.Unix(app.Session.GetInt64(r.Context(), pendingTwoFactorSinceKey), 0)
+	if time.Since(since) > twoFactorLoginWindow {
+		app.clearPendingTwoFactor(r)
+		SendJSON(w, http.StatusUnauthorized, nil, "This login has expired. Please log in again.")
+		return
+	}
+
+	user, err := app.UserModel.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching user: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to log in")
+		return
+	}
+
+	// Codes are guessed against the same counters as passwords
+	if !app.checkLoginThrottle(w, r, user.Email) {
+		return
+	}
+
+	var req struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		SendJSON(w, http.StatusBadRequest, nil, "A code or recovery_code is required")
+		return
+	}
+
+	var extra map[string]interface{}
+	if req.Code != "" {
+		err = app.checkTwoFactorCode(r, userID, req.Code)
+	} else {
+		var remaining int
+		remaining, err = app.TwoFactorModel.UseRecoveryCode(r.Context(), userID, hashRecoveryCode(req.RecoveryCode), auditSource(r))
+		extra = map[string]interface{}{"recovery_codes_remaining": remaining}
+	}
+	if err != nil {
+		if !errors.Is(err, models.ErrInvalidCode) {
+			sendTwoFactorError(w, err, "log in")
+			return
+		}
+
+		app.recordLoginFailure(r, user.Email)
+
+		attempts := app.Session.GetInt(r.Context(), pendingTwoFactorAttemptKey) + 1
+		if attempts >= twoFactorMaxAttempts {
+			app.clearPendingTwoFactor(r)
+			SendJSON(w, http.StatusUnauthorized, nil, "Too many invalid codes. Please log in again.")
+			return
+		}
+		app.Session.Put(r.Context(), pendingTwoFactorAttemptKey, attempts)
+		SendJSON(w, http.StatusUnauthorized, nil, "Invalid code")
+		return
+	}
+
+	app.completeLogin(w, r, user, extra)
+}
+
+// startTwoFactorLogin puts the session into the pending state after a
+// correct password for a user with two-factor authentication.
+func (app *Application) startTwoFactorLogin(w http.ResponseWriter, r *http.Request, userID int) {
+	if err := app.Session.RenewToken(r.Context()); err != nil {
+		log.Printf("Error renewing session token: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to log in")
+		return
+	}
+
+	app.Session.Put(r.Context(), pendingTwoFactorKey, userID)
+	app.Session.Put(r.Context(), pendingTwoFactorSinceKey, time.Now().Unix())
+	app.Session.Remove(r.Context(), pendingTwoFactorAttemptKey)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":             "Enter the code from your authenticator app",
+		"status":              http.StatusOK,
+		"two_factor_required": true,
+	})
+}
+
+func (app *Application) clearPendingTwoFactor(r *http.Request) {
+	app.Session.Remove(r.Context(), pendingTwoFactorKey)
+	app.Session.Remove(r.Context(), pendingTwoFactorSinceKey)
+	app.Session.Remove(r.Context(), pendingTwoFactorAttemptKey)
+}
+
+// checkTwoFactorCode validates a code against the user's enabled secret and
+// uses up its time step. It returns models.ErrInvalidCode for a wrong or
+// reused code.
+func (app *Application) checkTwoFactorCode(r *http.Request, userID int, code string) error {
+	secret, err := app.TwoFactorModel.GetSecret(r.Context(), userID, true)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return models.ErrInvalidCode
+	}
+	return app.TwoFactorModel.UseStep(r.Context(), userID, step)
+}
+
+// sendTwoFactorError maps two-factor errors to responses. action describes
+// what failed, e.g. "log in".
+func sendTwoFactorError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, models.ErrInvalidCode):
+		SendJSON(w, http.StatusUnauthorized, nil, "Invalid code")
+	case errors.Is(err, models.ErrNoRecord):
+		SendJSON(w, http.StatusConflict, nil, "Two-factor authentication is not enabled")
+	default:
+		log.Printf("Failed to %s: %v", action, err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to "+action)
+	}
+}
+
+// newRecoveryCodes generates a set of recovery codes in the form
+// "abcde-fghij-klmno-pqrst", along with their hashes for storage.
+func newRecoveryCodes() ([]string, [][]byte, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	chars := recoveryCodeGroups * recoveryCodeGroupChars
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([][]byte, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, (chars*5+7)/8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))[:chars]
+
+		groups := make([]string, 0, recoveryCodeGroups)
+		for j := 0; j < chars; j += recoveryCodeGroupChars {
+			groups = append(groups, raw[j:j+recoveryCodeGroupChars])
+		}
+		code := strings.Join(groups, "-")
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code as typed, ignoring case, spaces and
+// dashes.
+func hashRecoveryCode(code string) []byte {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return hashToken(code)
+}
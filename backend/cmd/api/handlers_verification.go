@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iankencruz/eggcounter/backend/internal/mailer"
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+// Verification emails and how often they can be resent.
+const (
+	verificationTTL         = 48 * time.Hour
+	verificationResendDelay = time.Minute
+	verificationDailyLimit  = 5
+)
+
+// Features that UNVERIFIED_RESTRICTIONS can withhold from users who haven't
+// confirmed their email address.
+const (
+	restrictFriendRequests = "friend_requests"
+	restrictUserSearch     = "user_search"
+	restrictExport         = "export"
+	restrictImport         = "import"
+	restrictTakeout        = "takeout"
+)
+
+// defaultRestrictions applies when UNVERIFIED_RESTRICTIONS isn't set.
+const defaultRestrictions = restrictFriendRequests
+
+// parseRestrictions reads a comma-separated list of restricted features, or
+// "none" to allow unverified users everything.
+func parseRestrictions(value string) (map[string]bool, error) {
+	restricted := map[string]bool{}
+	if strings.TrimSpace(value) == "none" {
+		return restricted, nil
+	}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+		case restrictFriendRequests, restrictUserSearch, restrictExport, restrictImport, restrictTakeout:
+			restricted[name] = true
+		default:
+			return nil, fmt.Errorf("unknown restriction %q", name)
+		}
+	}
+	return restricted, nil
+}
+
+// checkVerified reports whether the user may use a feature, sending a 403 if
+// they haven't verified their email address and the policy restricts it.
+func (app *Application) checkVerified(w http.ResponseWriter, r *http.Request, userID int, feature string) bool {
+	if !app.UnverifiedRestrictions[feature] {
+		return true
+	}
+
+	verified, err := app.UserModel.IsEmailVerified(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error checking email verification: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to check email verification")
+		return false
+	}
+	if !verified {
+		SendJSON(w, http.StatusForbidden, nil, "Please verify your email address first")
+		return false
+	}
+	return true
+}
+
+// startEmailVerification issues a new verification token for the user and
+// emails them the link. Earlier links stop working.
+func (app *Application) startEmailVerification(ctx context.Context, user *models.User) error {
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return err
+	}
+	if err := app.TokenModel.CreateToken(ctx, user.ID, models.TokenEmailVerification, tokenHash, verificationTTL); err != nil {
+		return err
+	}
+
+	link := app.BaseURL + "/api/verify-email?token=" + url.QueryEscape(token)
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your Egg Counter email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening this link within 48 hours:\n\n%s\n\nIf you didn't create an account, you can ignore this email.\n",
+			user.Username, link,
+		),
+	}
+
+	app.sendMail(msg)
+	return nil
+}
+
+// sendMail delivers msg in the background, since delivery can be slow and
+// shouldn't hold up the request. Failures are logged.
+func (app *Application) sendMail(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := app.Mailer.Send(ctx, msg); err != nil {
+			log.Printf("Error sending %q email: %v", msg.Subject, err)
+		}
+	}()
+}
+
+// verifyEmailHandler confirms the email address a verification link was sent
+// to. It doesn't need a session, so the link works on any device.
+func (app *Application) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		SendJSON(w, http.StatusBadRequest, nil, "Missing token")
+		return
+	}
+
+	if _, err := app.UserModel.VerifyEmail(r.Context(), hashToken(token)); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			SendJSON(w, http.StatusBadRequest, nil, "This verification link is invalid or has expired")
+			return
+		}
+		log.Printf("Error verifying email: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to verify email")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, nil, "Email address verified")
+}
+
+// resendVerificationHandler emails the user a fresh verification link. Sends
+// are limited to one a minute and verificationDailyLimit a day.
+func (app *Application) resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		SendJSON(w, http.StatusUnauthorized, nil, "Unauthorized. Please log in.")
+		return
+	}
+
+	user, err := app.UserModel.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching user: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to resend verification email")
+		return
+	}
+	if user.EmailVerified {
+		SendJSON(w, http.StatusConflict, nil, "Your email address is already verified")
+		return
+	}
+
+	now := time.Now()
+	activity, err := app.TokenModel.GetTokenActivity(r.Context(), userID, models.TokenEmailVerification, now.Add(-24*time.Hour))
+	if err != nil {
+		log.Printf("Error checking verification emails: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to resend verification email")
+		return
+	}
+
+	var retryAt time.Time
+	switch {
+	case activity.Count >= verificationDailyLimit:
+		retryAt = activity.Oldest.Add(24 * time.Hour)
+	case activity.Latest != nil && now.Sub(*activity.Latest) < verificationResendDelay:
+		retryAt = activity.Latest.Add(verificationResendDelay)
+	}
+	if !retryAt.IsZero() {
+		seconds := int(retryAt.Sub(now).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		SendJSON(w, http.StatusTooManyRequests, nil, "Please wait before requesting another verification email")
+		return
+	}
+
+	if err := app.startEmailVerification(r.Context(), user); err != nil {
+		log.Printf("Error starting email verification: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to resend verification email")
+		return
+	}
+
+	SendJSON(w, http.StatusOK, nil, "Verification email sent")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// jobInterval is how often background jobs run when nothing wakes them.
+const jobInterval = 30 * time.Second
+
+// runBackgroundJobs runs the periodic maintenance jobs until ctx is done.
+// Handlers that queue work call wakeJobs so it starts without waiting for
+// the next tick. Every job claims its work in the database, so running
+// several API instances is safe.
+func (app *Application) runBackgroundJobs(ctx context.Context) {
+	ticker := time.NewTicker(jobInterval)
+	defer ticker.Stop()
+
+	for {
+		app.processTakeouts(ctx)
+		app.purgeDueAccounts(ctx)
+
+		if n, err := app.TakeoutModel.ExpireTakeouts(ctx); err != nil {
+			log.Printf("Error expiring takeouts: %v", err)
+		} else if n > 0 {
+			log.Printf("Expired %d takeouts", n)
+		}
+
+		if _, err := app.SessionModel.DeleteStaleSessions(ctx); err != nil {
+			log.Printf("Error pruning sessions: %v", err)
+		}
+
+		cutoff := time.Now().Add(-max(accountThrottle.Window, ipThrottle.Window, passwordResetIPThrottle.Window))
+		if _, err := app.ThrottleModel.DeleteStale(ctx, cutoff); err != nil {
+			log.Printf("Error pruning login failures: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-app.jobWake:
+		}
+	}
+}
+
+// wakeJobs asks runBackgroundJobs to run now. It never blocks.
+func (app *Application) wakeJobs() {
+	select {
+	case app.jobWake <- struct{}{}:
+	default:
+	}
+}
@@ -2,24 +2,58 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata" // users pick IANA time zones; don't depend on the host's zoneinfo
 
 	"github.com/alexedwards/scs/pgxstore"
 	"github.com/alexedwards/scs/v2"
+	"github.com/iankencruz/eggcounter/backend/internal/mailer"
+	"github.com/iankencruz/eggcounter/backend/internal/migrations"
 	"github.com/iankencruz/eggcounter/backend/internal/models"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 )
 
 type Application struct {
-	DB          *pgxpool.Pool
-	Session     *scs.SessionManager
-	UserModel   *models.UserModel
-	EggModel    *models.EggModel
-	FriendModel *models.FriendModel
+	DB             *pgxpool.Pool
+	Session        *scs.SessionManager
+	UserModel      *models.UserModel
+	EggModel       *models.EggModel
+	FriendModel    *models.FriendModel
+	SettingsModel  *models.SettingsModel
+	StreakModel    *models.StreakModel
+	GoalModel      *models.GoalModel
+	EggTypeModel   *models.EggTypeModel
+	NutritionModel *models.NutritionModel
+	TagModel       *models.TagModel
+	RecipeModel    *models.RecipeModel
+	SessionModel   *models.SessionModel
+	TakeoutModel   *models.TakeoutModel
+	TokenModel     *models.TokenModel
+	TwoFactorModel *models.TwoFactorModel
+	ThrottleModel  *models.ThrottleModel
+	Mailer         mailer.Mailer
+
+	// BaseURL is where the frontend is served, for links in emails.
+	BaseURL string
+
+	// UnverifiedRestrictions are the features withheld from users until they
+	// verify their email address.
+	UnverifiedRestrictions map[string]bool
+
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For header is
+	// believed when working out a client's address. See realIP.
+	TrustedProxies []netip.Prefix
+
+	// jobWake nudges runBackgroundJobs when work is queued.
+	jobWake chan struct{}
 }
 
 func main() {
@@ -51,6 +85,19 @@ func main() {
 		log.Fatalf("Database ping failed: %v", err)
 	}
 
+	// Subcommands: `api migrate up|down|status`
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(dbpool, os.Args[2:]); err != nil {
+				log.Fatalf("Migration failed: %v", err)
+			}
+			return
+		default:
+			log.Fatalf("Unknown command %q (usage: api [migrate up|down [n]|status])", os.Args[1])
+		}
+	}
+
 	// Initialize PostgreSQL-backed session manager
 	sessionManager := scs.New()
 	sessionManager.Store = pgxstore.New(dbpool)
@@ -59,15 +106,64 @@ func main() {
 	sessionManager.Cookie.SameSite = http.SameSiteLaxMode
 	sessionManager.Cookie.Secure = false // Set to true in production
 
+	// Emails go through SMTP or, by default, the log
+	mail, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatalf("Mailer setup failed: %v", err)
+	}
+
+	baseURL := os.Getenv("APP_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	restrictions := os.Getenv("UNVERIFIED_RESTRICTIONS")
+	if restrictions == "" {
+		restrictions = defaultRestrictions
+	}
+	unverifiedRestrictions, err := parseRestrictions(restrictions)
+	if err != nil {
+		log.Fatalf("Invalid UNVERIFIED_RESTRICTIONS: %v", err)
+	}
+
+	// Only set this when the server sits behind a reverse proxy; otherwise
+	// clients could pick their own address with X-Forwarded-For.
+	trustedProxies, err := parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	app := &Application{
 
-		DB:          dbpool,
-		Session:     sessionManager,
-		UserModel:   &models.UserModel{DB: dbpool},
-		EggModel:    &models.EggModel{DB: dbpool},
-		FriendModel: &models.FriendModel{DB: dbpool},
+		DB:             dbpool,
+		Session:        sessionManager,
+		UserModel:      &models.UserModel{DB: dbpool},
+		EggModel:       &models.EggModel{DB: dbpool},
+		FriendModel:    &models.FriendModel{DB: dbpool},
+		SettingsModel:  &models.SettingsModel{DB: dbpool},
+		StreakModel:    &models.StreakModel{DB: dbpool},
+		GoalModel:      &models.GoalModel{DB: dbpool},
+		EggTypeModel:   &models.EggTypeModel{DB: dbpool},
+		NutritionModel: &models.NutritionModel{DB: dbpool},
+		TagModel:       &models.TagModel{DB: dbpool},
+		RecipeModel:    &models.RecipeModel{DB: dbpool},
+		SessionModel:   &models.SessionModel{DB: dbpool},
+		TakeoutModel:   &models.TakeoutModel{DB: dbpool},
+		TokenModel:     &models.TokenModel{DB: dbpool},
+		TwoFactorModel: &models.TwoFactorModel{DB: dbpool},
+		ThrottleModel:  &models.ThrottleModel{DB: dbpool},
+		Mailer:         mail,
+		BaseURL:        strings.TrimSuffix(baseURL, "/"),
+
+		UnverifiedRestrictions: unverifiedRestrictions,
+		TrustedProxies:         trustedProxies,
+
+		jobWake: make(chan struct{}, 1),
 	}
 
+	// Takeouts and cleanup run alongside the server
+	go app.runBackgroundJobs(context.Background())
+
 	// 3. Start the server
 	srv := &http.Server{
 		Addr:    ":8080",
@@ -82,3 +178,65 @@ func main() {
 	}
 
 }
+
+// runMigrate handles the `migrate` subcommand.
+func runMigrate(dbpool *pgxpool.Pool, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: api migrate up|down [n]|status")
+	}
+
+	ctx := context.Background()
+	migrator := migrations.NewMigrator(dbpool)
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			log.Printf("Applied %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Println("Database is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			log.Printf("Reverted %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			log.Println("No migrations to revert")
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q (want up, down or status)", args[0])
+	}
+
+	return nil
+}
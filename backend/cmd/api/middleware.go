@@ -2,12 +2,31 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
 )
 
 func (app *Application) requireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := app.Session.GetInt(r.Context(), "userID")
+		if userID != 0 {
+			current, err := app.sessionCurrent(r, userID)
+			if err != nil {
+				log.Printf("Error checking session epoch: %v", err)
+				SendJSON(w, http.StatusInternalServerError, nil, "Failed to check session")
+				return
+			}
+			if !current {
+				userID = 0
+			}
+		}
 		if userID == 0 {
 			// Send a 401 response without redirecting
 			w.WriteHeader(http.StatusUnauthorized)
@@ -19,3 +38,96 @@ func (app *Application) requireAuth(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// sessionCurrent reports whether the session still belongs to userID, i.e.
+// it logged in at the user's current session epoch. A revoked session is
+// destroyed. This catches sessions that user_sessions doesn't track, such
+// as those from before it existed.
+func (app *Application) sessionCurrent(r *http.Request, userID int) (bool, error) {
+	epoch, err := app.SessionModel.GetSessionEpoch(r.Context(), userID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		return false, err
+	}
+	if err == nil && app.Session.GetInt(r.Context(), "sessionEpoch") == epoch {
+		return true, nil
+	}
+
+	if err := app.Session.Destroy(r.Context()); err != nil {
+		log.Printf("Error destroying session: %v", err)
+	}
+	return false, nil
+}
+
+// parseTrustedProxies parses TRUSTED_PROXIES, a comma-separated list of
+// addresses or CIDR ranges such as "10.0.0.0/8, 127.0.0.1".
+func parseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(item); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an address or CIDR range", item)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// trustedProxy reports whether addr is one of app.TrustedProxies.
+func (app *Application) trustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range app.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// realIP sets r.RemoteAddr to the client's address when the request came
+// through one of app.TrustedProxies, so clientIP, and the login throttle
+// keyed on it, see the client rather than the proxy. X-Forwarded-For is read
+// from the right, skipping trusted proxies, and the first other address is
+// the client; anything to its left could have been made up by the client.
+// Requests from anywhere else keep their address and the header is ignored.
+// With no trusted proxies configured the server must face clients directly.
+func (app *Application) realIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, port, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		peer, err := netip.ParseAddr(host)
+		if err != nil || !app.trustedProxy(peer) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var hops []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(header, ",")...)
+		}
+		client := peer
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			client = addr
+			if !app.trustedProxy(addr) {
+				break
+			}
+		}
+
+		r.RemoteAddr = net.JoinHostPort(client.Unmap().String(), port)
+		next.ServeHTTP(w, r)
+	})
+}
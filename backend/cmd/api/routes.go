@@ -12,14 +12,21 @@ import (
 func (app *Application) routes() *chi.Mux {
 	router := chi.NewRouter()
 
-	// Load and save session middleware for all routes
+	// Work out the client's address first, then load and save the session
+	// for all routes
+	router.Use(app.realIP)
 	router.Use(app.Session.LoadAndSave)
 
 	// 🔐 Public routes
 	router.Post("/api/register", app.registerHandler)
 	router.Post("/api/login", app.loginHandler)
+	router.Post("/api/login/2fa", app.loginTwoFactorHandler)
 	router.Post("/api/logout", app.logoutHandler)
 	router.Get("/api/auth/status", app.authStatusHandler)
+	router.Post("/api/password/forgot", app.forgotPasswordHandler)
+	router.Post("/api/password/reset", app.resetPasswordHandler)
+	router.Get("/api/verify-email", app.verifyEmailHandler)
+	router.Get("/api/email/confirm", app.confirmEmailChangeHandler)
 
 	// 🔒 Protected API routes (Require Auth)
 	router.Route("/api", func(r chi.Router) {
@@ -27,17 +34,77 @@ func (app *Application) routes() *chi.Mux {
 
 		// 🥚 Egg Routes
 		r.Get("/dashboard", app.dashboardHandler)
-		r.Get("/eggcount", app.getEggCountHandler)         // Fetch total egg count
-		r.Post("/eggcount", app.addEggCountHandler)        // Add egg count
-		r.Delete("/eggcount/{id}", app.deleteEntryHandler) // Delete an egg count entry
+		r.Get("/eggcount", app.getEggCountHandler)               // Fetch total egg count
+		r.Post("/eggcount", app.addEggCountHandler)              // Add egg count
+		r.Get("/eggcount/entries", app.listEntriesHandler)       // Paginated, filterable entry history
+		r.Patch("/eggcount/{id}", app.updateEggEntryHandler)     // Edit an entry's amount, eaten_at, type or context
+		r.Delete("/eggcount/{id}", app.deleteEntryHandler)       // Undo an egg count entry
+		r.Post("/eggcount/{id}/redo", app.redoEntryHandler)      // Redo an undone entry
+		r.Get("/stats", app.statsHandler)                        // Bucketed consumption statistics
+		r.Get("/streaks", app.streaksHandler)                    // Current and longest streaks
+		r.Get("/nutrition/summary", app.nutritionSummaryHandler) // Bucketed nutritional intake
+		r.Get("/export", app.exportHandler)                      // Download every entry as csv, json or ndjson
+		r.Post("/import", app.importHandler)                     // Import entries from a CSV upload
+
+		// 🐣 Egg Type Routes
+		r.Get("/egg-types", app.listEggTypesHandler)                       // Built-in and custom egg types
+		r.Post("/egg-types", app.createEggTypeHandler)                     // Add a custom egg type
+		r.Put("/egg-types/{id}/nutrition", app.setEggTypeNutritionHandler) // Set a custom egg type's per-egg nutrition
+		r.Delete("/egg-types/{id}", app.deleteEggTypeHandler)              // Remove an unused custom egg type
+
+		// 🍳 Recipe Routes
+		r.Get("/recipes", app.listRecipesHandler)          // The user's recipes
+		r.Post("/recipes", app.createRecipeHandler)        // Add a recipe
+		r.Get("/recipes/usage", app.recipeUsageHandler)    // How often each recipe is logged
+		r.Get("/recipes/{id}", app.getRecipeHandler)       // View a recipe
+		r.Put("/recipes/{id}", app.updateRecipeHandler)    // Replace a recipe
+		r.Delete("/recipes/{id}", app.deleteRecipeHandler) // Remove a recipe, keeping its entries
+		r.Post("/eggcount/recipe", app.logRecipeHandler)   // Log servings of a recipe as entries
+
+		// 🏷️ Tag Routes
+		r.Get("/tags", app.listTagsHandler)          // The user's tags and how often they're used
+		r.Delete("/tags/{id}", app.deleteTagHandler) // Remove a tag from every entry
+
+		// ⚙️ Settings Routes
+		r.Get("/me/settings", app.getSettingsHandler)    // Time zone, week start and locale
+		r.Put("/me/settings", app.updateSettingsHandler) // Update settings
+
+		// 🗑️ Account Routes
+		r.Delete("/me", app.deleteAccountHandler)                        // Schedule account deletion (password required)
+		r.Post("/me/verify-email/resend", app.resendVerificationHandler) // Email a new verification link (throttled)
+		r.Put("/me/password", app.changePasswordHandler)                 // Change password (current password required)
+		r.Put("/me/email", app.changeEmailHandler)                       // Change email once the new address is confirmed
+
+		// 🔑 Two-Factor Routes
+		r.Post("/me/2fa/enroll", app.enrollTwoFactorHandler)   // Start enrollment: otpauth:// URI and QR code
+		r.Post("/me/2fa/confirm", app.confirmTwoFactorHandler) // Turn on 2FA with a first code; returns recovery codes
+		r.Delete("/me/2fa", app.disableTwoFactorHandler)       // Turn off 2FA (password and code required)
+
+		// 📦 Takeout Routes
+		r.Post("/me/takeout", app.createTakeoutHandler)           // Queue an archive of all your data
+		r.Get("/me/takeout/download", app.downloadTakeoutHandler) // Download a ready archive by token
+		r.Get("/me/takeout/{id}", app.getTakeoutHandler)          // Check a takeout's status
+
+		// 🎯 Goal Routes
+		r.Get("/me/goals", app.getGoalsHandler)    // Goals and today's progress
+		r.Put("/me/goals", app.updateGoalsHandler) // Replace goals
+
+		// 🔎 User Routes
+		r.Get("/users/search", app.searchUsersHandler) // Search users by username or email
 
 		// 👫 Friends Routes (Nested Group)
 		r.Route("/friends", func(fr chi.Router) {
-			fr.Post("/requests", app.sendFriendRequestHandler)      // Send a friend request
-			fr.Get("/requests", app.getFriendRequestsHandler)       // View pending friend requests
-			fr.Post("/accept/{id}", app.acceptFriendRequestHandler) // Accept a friend request by id
-			fr.Post("/reject/{id}", app.rejectFriendRequestHandler) // Reject a friend request by id
-			fr.Get("/", app.getFriendsListHandler)                  // Get all friends for the user
+			fr.Post("/requests", app.sendFriendRequestHandler)          // Send a friend request
+			fr.Get("/requests", app.getFriendRequestsHandler)           // View pending friend requests
+			fr.Get("/requests/sent", app.getSentFriendRequestsHandler)  // View pending requests you sent
+			fr.Delete("/requests/{id}", app.cancelFriendRequestHandler) // Cancel a request you sent
+			fr.Post("/accept/{id}", app.acceptFriendRequestHandler)     // Accept a friend request by id
+			fr.Post("/reject/{id}", app.rejectFriendRequestHandler)     // Reject a friend request by id
+			fr.Get("/", app.getFriendsListHandler)                      // Get all friends for the user
+			fr.Delete("/{userID}", app.removeFriendHandler)             // Unfriend a user
+			fr.Get("/blocked", app.getBlockedUsersHandler)              // List users you have blocked
+			fr.Post("/blocked", app.blockUserHandler)                   // Block a user
+			fr.Delete("/blocked/{userID}", app.unblockUserHandler)      // Unblock a user
 		})
 	})
 
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+// takeoutTTL is how long a finished takeout can be downloaded.
+const takeoutTTL = 48 * time.Hour
+
+// processTakeouts builds every queued takeout.
+func (app *Application) processTakeouts(ctx context.Context) {
+	for {
+		takeout, err := app.TakeoutModel.ClaimTakeout(ctx)
+		if err != nil {
+			log.Printf("Error claiming takeout: %v", err)
+			return
+		}
+		if takeout == nil {
+			return
+		}
+
+		archive, err := app.buildTakeout(ctx, takeout.UserID)
+		if err != nil {
+			log.Printf("Error building takeout %d: %v", takeout.ID, err)
+			if err := app.TakeoutModel.FailTakeout(ctx, takeout, "The archive could not be built. Please try again."); err != nil {
+				log.Printf("Error recording failed takeout %d: %v", takeout.ID, err)
+			}
+			continue
+		}
+
+		err = app.TakeoutModel.CompleteTakeout(ctx, takeout, archive, takeoutTTL)
+		if errors.Is(err, models.ErrTakeoutReclaimed) {
+			log.Printf("Takeout %d was reclaimed by another worker, discarding this archive", takeout.ID)
+		} else if err != nil {
+			log.Printf("Error saving takeout %d: %v", takeout.ID, err)
+		}
+	}
+}
+
+// buildTakeout writes a zip archive of everything stored about the user, one
+// JSON file per kind of data.
+func (app *Application) buildTakeout(ctx context.Context, userID int) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	profile, err := app.TakeoutModel.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("profile: %w", err)
+	}
+	if err := writeZipJSON(zw, "profile.json", profile); err != nil {
+		return nil, err
+	}
+
+	// Entries can be numerous, so they are encoded one at a time.
+	w, err := zw.Create("entries.json")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, "["); err != nil {
+		return nil, err
+	}
+	first := true
+	err = app.EggModel.EachEntry(ctx, userID, func(e *models.EggCount) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		return json.NewEncoder(w).Encode(e)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("entries: %w", err)
+	}
+	if _, err := io.WriteString(w, "]\n"); err != nil {
+		return nil, err
+	}
+
+	friendships, err := app.TakeoutModel.GetFriendships(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("friendships: %w", err)
+	}
+	blocked, err := app.FriendModel.GetBlockedUsers(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("blocked users: %w", err)
+	}
+	err = writeZipJSON(zw, "friendships.json", map[string]interface{}{
+		"friendships":   friendships,
+		"blocked_users": blocked,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := app.SettingsModel.GetSettings(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("settings: %w", err)
+	}
+	goals, err := app.GoalModel.GetGoals(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("goals: %w", err)
+	}
+	err = writeZipJSON(zw, "settings.json", map[string]interface{}{
+		"settings": settings,
+		"goals":    goals,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := app.TagModel.ListTags(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("tags: %w", err)
+	}
+	recipes, err := app.RecipeModel.ListRecipes(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("recipes: %w", err)
+	}
+	types, err := app.EggTypeModel.ListEggTypes(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("egg types: %w", err)
+	}
+	customTypes := []models.EggType{}
+	for _, t := range types {
+		if t.Custom {
+			customTypes = append(customTypes, t)
+		}
+	}
+	err = writeZipJSON(zw, "library.json", map[string]interface{}{
+		"tags":      tags,
+		"recipes":   recipes,
+		"egg_types": customTypes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := app.SessionModel.ListSessions(ctx, userID, "")
+	if err != nil {
+		return nil, fmt.Errorf("sessions: %w", err)
+	}
+	if err := writeZipJSON(zw, "sessions.json", sessions); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeZipJSON adds a file named name holding v as indented JSON.
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iankencruz/eggcounter/backend/internal/mailer"
+	"github.com/iankencruz/eggcounter/backend/internal/models"
+)
+
+// Login throttling. Each account allows a few free attempts, then doubles a
+// delay with every failure and locks out after accountThrottle.LockoutAfter.
+// The per-IP limits are looser, since many users can share an address.
+var (
+	accountThrottle = models.ThrottlePolicy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockoutAfter: 10,
+		Lockout:      15 * time.Minute,
+		Window:       time.Hour,
+	}
+	ipThrottle = models.ThrottlePolicy{
+		FreeAttempts: 20,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockoutAfter: 100,
+		Lockout:      15 * time.Minute,
+		Window:       time.Hour,
+	}
+)
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// checkLoginThrottle reports whether a login attempt for email may go ahead,
+// sending a 429 with Retry-After if the account or client is blocked.
+func (app *Application) checkLoginThrottle(w http.ResponseWriter, r *http.Request, email string) bool {
+	keys := []string{accountThrottleKey(email), ipThrottleKey(r)}
+	until, err := app.ThrottleModel.BlockedUntil(r.Context(), keys)
+	if err != nil {
+		log.Printf("Error checking login throttle: %v", err)
+		SendJSON(w, http.StatusInternalServerError, nil, "Failed to log in")
+		return false
+	}
+	if until.IsZero() {
+		return true
+	}
+
+	seconds := int(math.Ceil(time.Until(until).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	data := map[string]interface{}{
+		"retry_after": seconds,
+	}
+	SendJSON(w, http.StatusTooManyRequests, data, "Too many failed login attempts. Please try again later.")
+	return false
+}
+
+// recordLoginFailure counts a failed password or code against the account
+// and the client, emailing the account owner when it gets locked out.
+func (app *Application) recordLoginFailure(r *http.Request, email string) {
+	if _, _, err := app.ThrottleModel.RecordFailure(r.Context(), ipThrottleKey(r), ipThrottle); err != nil {
+		log.Printf("Error recording login failure: %v", err)
+	}
+
+	failures, until, err := app.ThrottleModel.RecordFailure(r.Context(), accountThrottleKey(email), accountThrottle)
+	if err != nil {
+		log.Printf("Error recording login failure: %v", err)
+		return
+	}
+	if failures == accountThrottle.LockoutAfter {
+		app.sendLockoutNotice(r, email, until)
+	}
+}
+
+// resetLoginFailures clears the account's counter after a successful login.
+// The client's counter is left alone, so one valid account can't be used to
+// keep guessing at others.
+func (app *Application) resetLoginFailures(r *http.Request, email string) {
+	if err := app.ThrottleModel.Reset(r.Context(), accountThrottleKey(email)); err != nil {
+		log.Printf("Error resetting login failures: %v", err)
+	}
+}
+
+// sendLockoutNotice tells the owner of email, if it belongs to an account,
+// that logins are locked until the given time.
+func (app *Application) sendLockoutNotice(r *http.Request, email string, until time.Time) {
+	user, err := app.UserModel.GetUserByEmail(r.Context(), strings.TrimSpace(email))
+	if err != nil {
+		if !errors.Is(err, models.ErrNoRecord) {
+			log.Printf("Error looking up locked out user: %v", err)
+		}
+		return
+	}
+
+	settings, err := app.SettingsModel.GetSettings(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error loading settings for lockout notice: %v", err)
+		defaults := models.DefaultSettings()
+		settings = &defaults
+	}
+
+	app.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your Egg Counter account has been locked",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThere were too many failed attempts to log in to your account, the last from %s, so logins are blocked until %s.\n\nIf this wasn't you, consider changing your password once you can log in again, or reset it now.\n",
+			user.Username, clientIP(r), settings.FormatTime(until),
+		),
+	})
+}
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"net"
 	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type APIResponse struct {
-	Data    interface{} `json:"data"` // Correctly wraps "data"
-	Message string      `json:"message"`
-	Status  int         `json:"status"`
+	Data       interface{} `json:"data"` // Correctly wraps "data"
+	Message    string      `json:"message"`
+	Status     int         `json:"status"`
+	NextCursor *string     `json:"next_cursor,omitempty"` // Set on paginated responses with more pages
 }
 
 func SendJSON(w http.ResponseWriter, statusCode int, data interface{}, message string) {
@@ -22,3 +30,67 @@ func SendJSON(w http.ResponseWriter, statusCode int, data interface{}, message s
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(response)
 }
+
+// SendPage sends one page of a paginated list. nextCursor is nil on the last
+// page, which leaves next_cursor out of the response.
+func SendPage(w http.ResponseWriter, data interface{}, nextCursor *string, message string) {
+	response := APIResponse{
+		Data:       data,
+		Message:    message,
+		Status:     http.StatusOK,
+		NextCursor: nextCursor,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// intURLParam reads a positive integer URL parameter such as {id}.
+func intURLParam(r *http.Request, name string) (int, error) {
+	value, err := strconv.Atoi(chi.URLParam(r, name))
+	if err != nil {
+		return 0, err
+	}
+	if value <= 0 {
+		return 0, strconv.ErrRange
+	}
+	return value, nil
+}
+
+// readIntQuery reads an integer query string parameter, falling back to def
+// when it is absent.
+func readIntQuery(r *http.Request, key string, def int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// newToken generates a random URL-safe token for links sent to users, along
+// with the hash to store in its place.
+func newToken() (string, []byte, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken hashes a token from newToken for lookup.
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// clientIP returns the address of the client that sent the request, which
+// realIP has already resolved if it came through a trusted proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
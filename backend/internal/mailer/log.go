@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// LogMailer is a Mailer for local development. It writes each message to a
+// .eml file in Dir, or to the log when Dir is empty, instead of sending it.
+type LogMailer struct {
+	Dir string
+
+	count atomic.Int64
+}
+
+// Send records msg.
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	if m.Dir == "" {
+		log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.eml", time.Now().Format("20060102-150405"), m.count.Add(1))
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, formatMessage("eggcounter@localhost", msg), 0o600); err != nil {
+		return err
+	}
+
+	log.Printf("Mail to %s written to %s", msg.To, path)
+	return nil
+}
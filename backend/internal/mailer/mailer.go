@@ -0,0 +1,58 @@
+// Package mailer sends the emails the API needs, such as password reset
+// links, through a pluggable Mailer.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromEnv builds the Mailer selected by the MAILER environment variable:
+//
+//   - smtp: SMTPMailer configured by SMTP_HOST, SMTP_PORT (default 587),
+//     SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM
+//   - log (the default): LogMailer, which writes messages to MAIL_DIR when
+//     set and to the log otherwise
+func FromEnv() (Mailer, error) {
+	switch kind := os.Getenv("MAILER"); kind {
+	case "smtp":
+		port := 587
+		if value := os.Getenv("SMTP_PORT"); value != "" {
+			p, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SMTP_PORT %q", value)
+			}
+			port = p
+		}
+
+		m := &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+		if m.Host == "" || m.From == "" {
+			return nil, fmt.Errorf("MAILER=smtp needs SMTP_HOST and MAIL_FROM")
+		}
+		return m, nil
+	case "", "log":
+		return &LogMailer{Dir: os.Getenv("MAIL_DIR")}, nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q (want smtp or log)", kind)
+	}
+}
@@ -0,0 +1,40 @@
+-- Baseline schema. Uses IF NOT EXISTS so databases that were created by hand
+-- before migrations existed can adopt this version without errors.
+
+CREATE TABLE IF NOT EXISTS users (
+    id            SERIAL PRIMARY KEY,
+    username      TEXT        NOT NULL UNIQUE,
+    email         TEXT        NOT NULL UNIQUE,
+    first_name    TEXT        NOT NULL DEFAULT '',
+    last_name     TEXT        NOT NULL DEFAULT '',
+    password_hash TEXT        NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS eggcount (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    amount     INTEGER     NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS eggcount_user_created_idx ON eggcount (user_id, created_at DESC);
+
+-- friends follows the user_id/friend_id shape used by the read queries in
+-- internal/models/friends.go.
+CREATE TABLE IF NOT EXISTS friends (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    friend_id  INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status     TEXT        NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Session storage for github.com/alexedwards/scs/pgxstore.
+CREATE TABLE IF NOT EXISTS sessions (
+    token  TEXT PRIMARY KEY,
+    data   BYTEA       NOT NULL,
+    expiry TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS sessions_expiry_idx ON sessions (expiry);
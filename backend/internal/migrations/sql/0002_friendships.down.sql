@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS friends_to_user_idx;
+DROP INDEX IF EXISTS friends_from_user_idx;
+DROP INDEX IF EXISTS friends_pair_live_idx;
+
+ALTER TABLE friends
+    DROP CONSTRAINT IF EXISTS friends_not_self_check,
+    DROP CONSTRAINT IF EXISTS friends_status_check,
+    DROP COLUMN IF EXISTS responded_at;
+
+ALTER TABLE friends RENAME COLUMN from_user_id TO user_id;
+ALTER TABLE friends RENAME COLUMN to_user_id TO friend_id;
@@ -0,0 +1,43 @@
+-- Databases created before migrations existed used either from_user_id/to_user_id
+-- (what SendFriendRequest wrote) or user_id/friend_id (what the list queries
+-- read). Normalise both to from_user_id/to_user_id.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema()
+          AND table_name = 'friends'
+          AND column_name = 'user_id'
+    ) THEN
+        ALTER TABLE friends RENAME COLUMN user_id TO from_user_id;
+        ALTER TABLE friends RENAME COLUMN friend_id TO to_user_id;
+    END IF;
+END
+$$;
+
+ALTER TABLE friends ADD COLUMN responded_at TIMESTAMPTZ;
+
+-- Clean up rows the new constraints would reject: self-requests and
+-- duplicate live rows for the same pair (the oldest one wins).
+DELETE FROM friends WHERE from_user_id = to_user_id;
+
+DELETE FROM friends f
+USING friends g
+WHERE f.status IN ('pending', 'accepted')
+  AND g.status IN ('pending', 'accepted')
+  AND LEAST(f.from_user_id, f.to_user_id) = LEAST(g.from_user_id, g.to_user_id)
+  AND GREATEST(f.from_user_id, f.to_user_id) = GREATEST(g.from_user_id, g.to_user_id)
+  AND f.id > g.id;
+
+ALTER TABLE friends
+    ADD CONSTRAINT friends_status_check CHECK (status IN ('pending', 'accepted', 'rejected')),
+    ADD CONSTRAINT friends_not_self_check CHECK (from_user_id <> to_user_id);
+
+-- A pair of users has at most one live (pending or accepted) friendship,
+-- regardless of who sent the request.
+CREATE UNIQUE INDEX friends_pair_live_idx
+    ON friends (LEAST(from_user_id, to_user_id), GREATEST(from_user_id, to_user_id))
+    WHERE status IN ('pending', 'accepted');
+
+CREATE INDEX friends_from_user_idx ON friends (from_user_id, status);
+CREATE INDEX friends_to_user_idx ON friends (to_user_id, status);
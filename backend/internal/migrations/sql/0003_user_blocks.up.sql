@@ -0,0 +1,9 @@
+CREATE TABLE user_blocks (
+    blocker_id INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    blocked_id INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (blocker_id, blocked_id),
+    CONSTRAINT user_blocks_not_self_check CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX user_blocks_blocked_idx ON user_blocks (blocked_id);
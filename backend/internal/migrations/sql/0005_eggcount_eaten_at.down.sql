@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS eggcount_user_eaten_idx;
+CREATE INDEX IF NOT EXISTS eggcount_user_created_idx ON eggcount (user_id, created_at DESC);
+
+ALTER TABLE eggcount
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS eaten_at;
@@ -0,0 +1,12 @@
+-- eaten_at is when the eggs were actually eaten, which the user may backdate;
+-- created_at remains the time the entry was logged.
+ALTER TABLE eggcount ADD COLUMN eaten_at TIMESTAMPTZ;
+UPDATE eggcount SET eaten_at = created_at;
+ALTER TABLE eggcount
+    ALTER COLUMN eaten_at SET NOT NULL,
+    ALTER COLUMN eaten_at SET DEFAULT NOW();
+
+ALTER TABLE eggcount ADD COLUMN updated_at TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS eggcount_user_created_idx;
+CREATE INDEX eggcount_user_eaten_idx ON eggcount (user_id, eaten_at DESC, id DESC);
@@ -0,0 +1,11 @@
+-- Reversal rows keep their negative amounts, so dropping the link and status
+-- restores the old sum-everything behaviour. Reversals that were themselves
+-- reverted (by a redo) would then double count, so remove them first.
+DELETE FROM eggcount WHERE reverts_entry_id IS NOT NULL AND status = 'reverted';
+
+DROP INDEX IF EXISTS eggcount_active_reversal_idx;
+
+ALTER TABLE eggcount
+    DROP CONSTRAINT IF EXISTS eggcount_status_check,
+    DROP COLUMN IF EXISTS reverts_entry_id,
+    DROP COLUMN IF EXISTS status;
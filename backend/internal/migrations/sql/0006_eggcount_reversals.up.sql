@@ -0,0 +1,42 @@
+-- Undo no longer inserts an unlinked negative row. Instead the original entry
+-- is marked 'reverted' and a reversal row pointing at it is kept as an audit
+-- trail. Only active, non-reversal rows count towards totals.
+ALTER TABLE eggcount
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'active',
+    ADD COLUMN reverts_entry_id INTEGER REFERENCES eggcount (id) ON DELETE CASCADE,
+    ADD CONSTRAINT eggcount_status_check CHECK (status IN ('active', 'reverted'));
+
+-- At most one reversal can be in effect for an entry, which keeps undo idempotent.
+CREATE UNIQUE INDEX eggcount_active_reversal_idx
+    ON eggcount (reverts_entry_id)
+    WHERE status = 'active';
+
+-- Best-effort link of legacy negative "undo" rows to the most recent earlier
+-- entry with the same amount. Totals are unchanged either way: a linked pair
+-- stops counting on both sides, an unmatched negative keeps counting as an
+-- adjustment.
+DO $$
+DECLARE
+    neg    RECORD;
+    target RECORD;
+BEGIN
+    FOR neg IN SELECT id, user_id, amount FROM eggcount WHERE amount < 0 ORDER BY id LOOP
+        SELECT id, eaten_at INTO target
+        FROM eggcount
+        WHERE user_id = neg.user_id
+          AND amount = -neg.amount
+          AND id < neg.id
+          AND status = 'active'
+          AND reverts_entry_id IS NULL
+        ORDER BY id DESC
+        LIMIT 1;
+
+        IF FOUND THEN
+            UPDATE eggcount SET status = 'reverted' WHERE id = target.id;
+            UPDATE eggcount
+            SET reverts_entry_id = target.id, eaten_at = target.eaten_at
+            WHERE id = neg.id;
+        END IF;
+    END LOOP;
+END
+$$;
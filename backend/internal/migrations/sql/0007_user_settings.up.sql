@@ -0,0 +1,10 @@
+-- Users without a row use the defaults below (see models.DefaultSettings).
+-- week_start follows Go's time.Weekday and Postgres' EXTRACT(DOW): 0 is Sunday.
+CREATE TABLE user_settings (
+    user_id    INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    time_zone  TEXT        NOT NULL DEFAULT 'UTC',
+    week_start SMALLINT    NOT NULL DEFAULT 1,
+    locale     TEXT        NOT NULL DEFAULT 'en',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT user_settings_week_start_check CHECK (week_start BETWEEN 0 AND 6)
+);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS eggcount_streaks_stale ON eggcount;
+DROP FUNCTION IF EXISTS eggcount_mark_streaks_stale();
+DROP TABLE IF EXISTS user_streaks;
@@ -0,0 +1,42 @@
+-- Cached streak state per user, kind and threshold, covering every day up to
+-- and including computed_through (in time_zone). Requests only scan days
+-- after computed_through. Writes to eggcount record the earliest affected
+-- instant in stale_from, and the cache is rebuilt when that falls on an
+-- already computed day.
+CREATE TABLE user_streaks (
+    user_id          INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind             TEXT        NOT NULL,
+    threshold        INTEGER     NOT NULL,
+    time_zone        TEXT        NOT NULL,
+    computed_through DATE        NOT NULL,
+    run_length       INTEGER     NOT NULL DEFAULT 0,
+    run_start        DATE,
+    longest_length   INTEGER     NOT NULL DEFAULT 0,
+    longest_start    DATE,
+    longest_end      DATE,
+    stale_from       TIMESTAMPTZ,
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, kind, threshold),
+    CONSTRAINT user_streaks_kind_check CHECK (kind IN ('minimum', 'under_limit'))
+);
+
+CREATE FUNCTION eggcount_mark_streaks_stale() RETURNS trigger AS $$
+BEGIN
+    -- LEAST ignores NULLs, so an unset stale_from takes the new value.
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE user_streaks
+        SET stale_from = LEAST(stale_from, OLD.eaten_at)
+        WHERE user_id = OLD.user_id;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE user_streaks
+        SET stale_from = LEAST(stale_from, NEW.eaten_at)
+        WHERE user_id = NEW.user_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER eggcount_streaks_stale
+    AFTER INSERT OR UPDATE OR DELETE ON eggcount
+    FOR EACH ROW EXECUTE FUNCTION eggcount_mark_streaks_stale();
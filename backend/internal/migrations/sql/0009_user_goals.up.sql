@@ -0,0 +1,10 @@
+-- NULL means the user has no goal of that kind.
+CREATE TABLE user_goals (
+    user_id    INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    daily_max  INTEGER,
+    weekly_max INTEGER,
+    daily_min  INTEGER,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT user_goals_non_negative_check
+        CHECK (daily_max >= 0 AND weekly_max >= 0 AND daily_min >= 0)
+);
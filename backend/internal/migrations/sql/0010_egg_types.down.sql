@@ -0,0 +1,3 @@
+ALTER TABLE eggcount DROP COLUMN IF EXISTS egg_type_id;
+DROP FUNCTION IF EXISTS default_egg_type_id();
+DROP TABLE IF EXISTS egg_types;
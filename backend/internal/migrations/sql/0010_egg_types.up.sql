@@ -0,0 +1,39 @@
+-- Catalog of egg types. Built-in types have no owner and a slug; users can add
+-- their own types, which only they can see.
+CREATE TABLE egg_types (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER REFERENCES users (id) ON DELETE CASCADE,
+    slug       TEXT UNIQUE,
+    species    TEXT        NOT NULL,
+    size       TEXT        NOT NULL,
+    name       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT egg_types_builtin_slug_check CHECK ((user_id IS NULL) = (slug IS NOT NULL))
+);
+
+CREATE UNIQUE INDEX egg_types_owner_species_size_idx
+    ON egg_types (COALESCE(user_id, 0), LOWER(species), LOWER(size));
+
+INSERT INTO egg_types (slug, species, size, name) VALUES
+    ('chicken-small',       'chicken', 'small',       'Small chicken egg'),
+    ('chicken-medium',      'chicken', 'medium',      'Medium chicken egg'),
+    ('chicken-large',       'chicken', 'large',       'Large chicken egg'),
+    ('chicken-extra-large', 'chicken', 'extra large', 'Extra large chicken egg'),
+    ('chicken-jumbo',       'chicken', 'jumbo',       'Jumbo chicken egg'),
+    ('duck',                'duck',    'standard',    'Duck egg'),
+    ('quail',               'quail',   'standard',    'Quail egg'),
+    ('goose',               'goose',   'standard',    'Goose egg');
+
+-- Entries that don't say otherwise are large chicken eggs, which is what
+-- every entry logged before this migration is assumed to be.
+CREATE FUNCTION default_egg_type_id() RETURNS INTEGER
+    LANGUAGE sql STABLE
+    AS $$ SELECT id FROM egg_types WHERE slug = 'chicken-large' $$;
+
+ALTER TABLE eggcount ADD COLUMN egg_type_id INTEGER REFERENCES egg_types (id);
+UPDATE eggcount SET egg_type_id = default_egg_type_id();
+ALTER TABLE eggcount
+    ALTER COLUMN egg_type_id SET NOT NULL,
+    ALTER COLUMN egg_type_id SET DEFAULT default_egg_type_id();
+
+CREATE INDEX eggcount_egg_type_idx ON eggcount (egg_type_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS egg_nutrition;
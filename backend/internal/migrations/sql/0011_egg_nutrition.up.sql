@@ -0,0 +1,26 @@
+-- Nutrition per single egg of each type. Built-in values are approximate
+-- figures for a whole raw egg; custom types may have no row, in which case
+-- their eggs are reported as unknown.
+CREATE TABLE egg_nutrition (
+    egg_type_id    INTEGER PRIMARY KEY REFERENCES egg_types (id) ON DELETE CASCADE,
+    protein_g      NUMERIC(7, 2) NOT NULL,
+    calories_kcal  NUMERIC(7, 2) NOT NULL,
+    fat_g          NUMERIC(7, 2) NOT NULL,
+    cholesterol_mg NUMERIC(7, 2) NOT NULL,
+    CONSTRAINT egg_nutrition_non_negative_check
+        CHECK (protein_g >= 0 AND calories_kcal >= 0 AND fat_g >= 0 AND cholesterol_mg >= 0)
+);
+
+INSERT INTO egg_nutrition (egg_type_id, protein_g, calories_kcal, fat_g, cholesterol_mg)
+SELECT egg_types.id, v.protein_g, v.calories_kcal, v.fat_g, v.cholesterol_mg
+FROM (VALUES
+    ('chicken-small',        4.80,  54,  3.60,  141),
+    ('chicken-medium',       5.50,  63,  4.20,  163),
+    ('chicken-large',        6.30,  72,  4.80,  186),
+    ('chicken-extra-large',  7.00,  80,  5.30,  208),
+    ('chicken-jumbo',        7.90,  90,  6.00,  234),
+    ('duck',                 9.00, 130,  9.60,  619),
+    ('quail',                1.20,  14,  1.00,   76),
+    ('goose',               20.00, 266, 19.10, 1227)
+) AS v (slug, protein_g, calories_kcal, fat_g, cholesterol_mg)
+JOIN egg_types ON egg_types.slug = v.slug;
@@ -0,0 +1,28 @@
+-- Optional meal and note for an entry. Reversal rows never have one.
+CREATE TABLE entry_context (
+    entry_id   INTEGER PRIMARY KEY REFERENCES eggcount (id) ON DELETE CASCADE,
+    meal       TEXT,
+    note       TEXT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT entry_context_meal_check
+        CHECK (meal IN ('breakfast', 'lunch', 'dinner', 'snack')),
+    CONSTRAINT entry_context_note_length_check CHECK (char_length(note) <= 1000)
+);
+
+-- Tags are defined per user and matched case-insensitively.
+CREATE TABLE tags (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX tags_user_name_idx ON tags (user_id, LOWER(name));
+
+CREATE TABLE entry_tags (
+    entry_id INTEGER NOT NULL REFERENCES eggcount (id) ON DELETE CASCADE,
+    tag_id   INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (entry_id, tag_id)
+);
+
+CREATE INDEX entry_tags_tag_id_idx ON entry_tags (tag_id);
@@ -0,0 +1,4 @@
+ALTER TABLE eggcount DROP COLUMN IF EXISTS recipe_log_id;
+DROP TABLE IF EXISTS recipe_logs;
+DROP TABLE IF EXISTS recipe_eggs;
+DROP TABLE IF EXISTS recipes;
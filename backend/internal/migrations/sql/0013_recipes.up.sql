@@ -0,0 +1,40 @@
+-- Recipes describe the eggs in one serving of a dish so it can be logged
+-- without doing the arithmetic by hand.
+CREATE TABLE recipes (
+    id          SERIAL PRIMARY KEY,
+    user_id     INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name        TEXT        NOT NULL,
+    description TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX recipes_user_name_idx ON recipes (user_id, LOWER(name));
+
+-- The eggs of each type in one serving.
+CREATE TABLE recipe_eggs (
+    recipe_id   INTEGER NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    egg_type_id INTEGER NOT NULL REFERENCES egg_types (id),
+    amount      INTEGER NOT NULL CHECK (amount > 0),
+    PRIMARY KEY (recipe_id, egg_type_id)
+);
+
+CREATE INDEX recipe_eggs_egg_type_id_idx ON recipe_eggs (egg_type_id);
+
+-- Each time a recipe is logged. The eggcount entries it expanded into point
+-- back here through recipe_log_id.
+CREATE TABLE recipe_logs (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    recipe_id  INTEGER     NOT NULL REFERENCES recipes (id) ON DELETE CASCADE,
+    servings   INTEGER     NOT NULL CHECK (servings > 0),
+    eaten_at   TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX recipe_logs_recipe_id_idx ON recipe_logs (recipe_id);
+
+ALTER TABLE eggcount
+    ADD COLUMN recipe_log_id INTEGER REFERENCES recipe_logs (id) ON DELETE SET NULL;
+
+CREATE INDEX eggcount_recipe_log_id_idx ON eggcount (recipe_log_id) WHERE recipe_log_id IS NOT NULL;
@@ -0,0 +1,13 @@
+-- Who each session belongs to. scs keeps the session data itself in the
+-- sessions table; this records metadata captured at login so a user's
+-- sessions can be listed, exported and revoked. Rows whose session has
+-- expired are pruned in the background.
+CREATE TABLE user_sessions (
+    token      TEXT PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    ip_address TEXT        NOT NULL DEFAULT '',
+    user_agent TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX user_sessions_user_id_idx ON user_sessions (user_id);
@@ -0,0 +1,23 @@
+-- Account data exports. A background job builds the zip archive for each
+-- pending row; it can then be downloaded with the token until expires_at.
+CREATE TABLE takeouts (
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status       TEXT        NOT NULL DEFAULT 'pending',
+    token_hash   BYTEA       NOT NULL UNIQUE,
+    archive      BYTEA,
+    error        TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at   TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    expires_at   TIMESTAMPTZ,
+    CONSTRAINT takeouts_status_check
+        CHECK (status IN ('pending', 'running', 'ready', 'failed', 'expired'))
+);
+
+CREATE INDEX takeouts_user_id_idx ON takeouts (user_id);
+CREATE INDEX takeouts_pending_idx ON takeouts (created_at) WHERE status IN ('pending', 'running');
+
+-- Only one takeout per user can be in progress at a time.
+CREATE UNIQUE INDEX takeouts_one_in_progress_idx
+    ON takeouts (user_id) WHERE status IN ('pending', 'running');
@@ -0,0 +1,7 @@
+-- Accounts scheduled for deletion. Logging in before delete_after cancels
+-- it; afterwards a background job purges the account.
+ALTER TABLE users
+    ADD COLUMN deletion_requested_at TIMESTAMPTZ,
+    ADD COLUMN delete_after          TIMESTAMPTZ;
+
+CREATE INDEX users_delete_after_idx ON users (delete_after) WHERE delete_after IS NOT NULL;
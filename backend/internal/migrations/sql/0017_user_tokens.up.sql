@@ -0,0 +1,13 @@
+-- Single-use tokens emailed to users, such as password reset links. Only a
+-- SHA-256 hash of each token is stored.
+CREATE TABLE user_tokens (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose    TEXT        NOT NULL,
+    token_hash BYTEA       NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX user_tokens_user_purpose_idx ON user_tokens (user_id, purpose, created_at DESC);
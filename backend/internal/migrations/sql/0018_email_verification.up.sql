@@ -0,0 +1,5 @@
+-- New accounts start unverified until the emailed link is opened. Accounts
+-- that existed before verification was introduced count as verified.
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
+
+UPDATE users SET email_verified_at = created_at;
@@ -0,0 +1,3 @@
+ALTER TABLE user_tokens DROP COLUMN IF EXISTS email;
+
+DROP TABLE IF EXISTS audit_log;
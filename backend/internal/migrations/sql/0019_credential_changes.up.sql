@@ -0,0 +1,16 @@
+-- A record of security-sensitive changes to an account, such as a new
+-- password or email address.
+CREATE TABLE audit_log (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    action     TEXT        NOT NULL,
+    detail     JSONB       NOT NULL DEFAULT '{}',
+    ip_address TEXT        NOT NULL DEFAULT '',
+    user_agent TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_log_user_created_idx ON audit_log (user_id, created_at DESC);
+
+-- The new address an email change token confirms.
+ALTER TABLE user_tokens ADD COLUMN email TEXT;
@@ -0,0 +1,20 @@
+-- TOTP two-factor authentication. A row without confirmed_at is an
+-- enrollment waiting for its first code. last_used_step stops a code being
+-- used twice.
+CREATE TABLE user_totp (
+    user_id        INTEGER     PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret         TEXT        NOT NULL,
+    confirmed_at   TIMESTAMPTZ,
+    last_used_step BIGINT      NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Single-use codes for logging in without the authenticator. Only a SHA-256
+-- hash of each code is stored.
+CREATE TABLE recovery_codes (
+    id        SERIAL PRIMARY KEY,
+    user_id   INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash BYTEA       NOT NULL,
+    used_at   TIMESTAMPTZ,
+    UNIQUE (user_id, code_hash)
+);
@@ -0,0 +1,11 @@
+-- Failed login counters, one row per account (keyed by email, so unknown
+-- addresses are throttled too) and per client IP. Keeping them in Postgres
+-- lets every API instance share them.
+CREATE TABLE login_failures (
+    key             TEXT        PRIMARY KEY,
+    failures        INTEGER     NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    blocked_until   TIMESTAMPTZ
+);
+
+CREATE INDEX login_failures_last_failure_idx ON login_failures (last_failure_at);
@@ -0,0 +1,4 @@
+-- Counts how often a user's sessions have been revoked. Each session stores
+-- the value it logged in with and stops working once they differ, which
+-- also covers sessions from before user_sessions existed.
+ALTER TABLE users ADD COLUMN session_epoch INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Audited actions stored in audit_log.action.
+const (
+	AuditPasswordChanged      = "password_changed"
+	AuditEmailChangeRequested = "email_change_requested"
+	AuditEmailChanged         = "email_changed"
+	AuditTwoFactorEnabled     = "two_factor_enabled"
+	AuditTwoFactorDisabled    = "two_factor_disabled"
+	AuditRecoveryCodeUsed     = "recovery_code_used"
+)
+
+// AuditSource identifies the client that made an audited change.
+type AuditSource struct {
+	IPAddress string
+	UserAgent string
+}
+
+// recordAudit adds an audit_log entry inside tx, so it is only kept if the
+// change it describes is.
+func recordAudit(ctx context.Context, tx pgx.Tx, userID int, action string, detail map[string]string, source AuditSource) error {
+	if detail == nil {
+		detail = map[string]string{}
+	}
+
+	query := `
+		INSERT INTO audit_log (user_id, action, detail, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := tx.Exec(ctx, query, userID, action, detail, source.IPAddress, source.UserAgent)
+	return err
+}
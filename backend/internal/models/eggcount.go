@@ -2,17 +2,65 @@ package models
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// EggCount represents an egg consumption record.
+// MaxClockSkew is how far into the future an eaten_at may be before it is
+// rejected, to tolerate clients whose clocks run slightly fast. Handlers that
+// check eaten_at before reaching the model use it too.
+const MaxClockSkew = time.Minute
+
+// Entry statuses stored in eggcount.status.
+const (
+	EntryStatusActive   = "active"
+	EntryStatusReverted = "reverted"
+)
+
+// countedEntries restricts an eggcount query to the rows that count towards
+// totals: entries that haven't been undone, excluding the reversal rows that
+// record each undo.
+const countedEntries = `eggcount.status = 'active' AND eggcount.reverts_entry_id IS NULL`
+
+// eggCountColumns is the column list scanned by scanEggCount.
+const eggCountColumns = `eggcount.id, eggcount.user_id, eggcount.amount, eggcount.eaten_at,
+	eggcount.egg_type_id, (SELECT egg_types.name FROM egg_types WHERE egg_types.id = eggcount.egg_type_id),
+	eggcount.status, eggcount.reverts_entry_id, eggcount.created_at, eggcount.updated_at,
+	(SELECT entry_context.meal FROM entry_context WHERE entry_context.entry_id = eggcount.id),
+	(SELECT entry_context.note FROM entry_context WHERE entry_context.entry_id = eggcount.id),
+	ARRAY(
+		SELECT tags.name FROM entry_tags JOIN tags ON tags.id = entry_tags.tag_id
+		WHERE entry_tags.entry_id = eggcount.id ORDER BY LOWER(tags.name)
+	),
+	(SELECT recipe_logs.recipe_id FROM recipe_logs WHERE recipe_logs.id = eggcount.recipe_log_id)`
+
+// EggCount represents an egg consumption record. EatenAt is when the eggs
+// were eaten and may be earlier than CreatedAt, when the entry was logged.
+//
+// Undoing an entry sets its Status to reverted and records a reversal row
+// whose RevertsEntryID points back at it.
+//
+// Meal, Note and Tags are optional context kept in the entry_context and
+// entry_tags tables. RecipeID is set on entries logged from a recipe.
 type EggCount struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	Amount    int       `json:"amount"`
-	CreatedAt time.Time `json:"created_at"`
+	ID             int        `json:"id"`
+	UserID         int        `json:"user_id"`
+	Amount         int        `json:"amount"`
+	EatenAt        time.Time  `json:"eaten_at"`
+	EggTypeID      int        `json:"egg_type_id"`
+	EggType        string     `json:"egg_type"`
+	Status         string     `json:"status"`
+	RevertsEntryID *int       `json:"reverts_entry_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      *time.Time `json:"updated_at"`
+	Meal           *string    `json:"meal"`
+	Note           *string    `json:"note"`
+	Tags           []string   `json:"tags"`
+	RecipeID       *int       `json:"recipe_id"`
+	Nutrition      *Nutrition `json:"nutrition,omitempty"`
 }
 
 // EggModel handles database operations for the eggcount table.
@@ -25,14 +73,100 @@ func NewEggModel(db *pgxpool.Pool) *EggModel {
 	return &EggModel{DB: db}
 }
 
-// AddEggCount adds a new egg consumption record for the user.
-func (m *EggModel) AddEggCount(ctx context.Context, userID, amount int) error {
-	query := `
-		INSERT INTO eggcount (user_id, amount)
-		VALUES ($1, $2)
-	`
-	_, err := m.DB.Exec(ctx, query, userID, amount)
-	return err
+// NewEntry describes an entry to add with AddEggCount. A zero EatenAt means
+// now, and a nil EggTypeID means a large chicken egg. Meal, Note and Tags are
+// optional.
+type NewEntry struct {
+	Amount    int
+	EatenAt   time.Time
+	EggTypeID *int
+	Meal      string
+	Note      string
+	Tags      []string
+}
+
+// EntryUpdate describes changes for UpdateEggEntry. Nil fields are left
+// unchanged; an empty Meal or Note clears it and an empty Tags removes them all.
+type EntryUpdate struct {
+	Amount    *int
+	EatenAt   *time.Time
+	EggTypeID *int
+	Meal      *string
+	Note      *string
+	Tags      *[]string
+}
+
+// AddEggCount adds a new egg consumption record for the user. It returns
+// ErrEatenAtInFuture for an eaten_at in the future and ErrUnknownEggType for
+// an egg type the user cannot use.
+func (m *EggModel) AddEggCount(ctx context.Context, userID int, e NewEntry) (*EggCount, error) {
+	if e.EatenAt.IsZero() {
+		e.EatenAt = time.Now()
+	}
+	if e.EatenAt.After(time.Now().Add(MaxClockSkew)) {
+		return nil, ErrEatenAtInFuture
+	}
+	if e.EggTypeID != nil {
+		if err := checkEggType(ctx, m.DB, userID, *e.EggTypeID); err != nil {
+			return nil, err
+		}
+	}
+
+	var entry *EggCount
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		var err error
+		entry, err = insertEntry(ctx, tx, userID, e, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// UpdateEggEntry changes one of the user's active entries. It returns
+// ErrNoRecord if the entry doesn't exist, belongs to another user or has been
+// undone, plus the validation errors of AddEggCount.
+func (m *EggModel) UpdateEggEntry(ctx context.Context, userID, entryID int, u EntryUpdate) (*EggCount, error) {
+	if u.EatenAt != nil && u.EatenAt.After(time.Now().Add(MaxClockSkew)) {
+		return nil, ErrEatenAtInFuture
+	}
+	if u.EggTypeID != nil {
+		if err := checkEggType(ctx, m.DB, userID, *u.EggTypeID); err != nil {
+			return nil, err
+		}
+	}
+
+	var entry *EggCount
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		query := `
+			UPDATE eggcount
+			SET amount = COALESCE($3, amount),
+				eaten_at = COALESCE($4, eaten_at),
+				egg_type_id = COALESCE($5, egg_type_id),
+				updated_at = NOW()
+			WHERE id = $1 AND user_id = $2 AND ` + countedEntries + `
+			RETURNING id
+		`
+		err := tx.QueryRow(ctx, query, entryID, userID, u.Amount, u.EatenAt, u.EggTypeID).Scan(&entryID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoRecord
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := saveEntryContext(ctx, tx, userID, entryID, u.Meal, u.Note, u.Tags); err != nil {
+			return err
+		}
+
+		entry, err = loadEntry(ctx, tx, entryID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
 }
 
 // GetTotalEggCount retrieves the total number of eggs consumed by a user.
@@ -41,40 +175,91 @@ func (m *EggModel) GetTotalEggCount(ctx context.Context, userID int) (int, error
 	query := `
 		SELECT COALESCE(SUM(amount), 0)
 		FROM eggcount
-		WHERE user_id = $1
-	`
+		WHERE user_id = $1 AND ` + countedEntries
+
 	err := m.DB.QueryRow(ctx, query, userID).Scan(&totalEggs)
 	return totalEggs, err
 }
 
-// GetRecentEggEntries retrieves the most recent egg consumption records for a user.
-func (m *EggModel) GetRecentEggEntries(ctx context.Context, userID int, limit int) ([]EggCount, error) {
-	query := `
-		SELECT id, user_id, amount, created_at
-		FROM eggcount
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2
-	`
+// GetRecentEggEntries retrieves the most recent egg consumption records for a
+// user that match the filter. Undone entries are included with a reverted
+// status so they can be redone; the reversal rows themselves are left out.
+func (m *EggModel) GetRecentEggEntries(ctx context.Context, userID int, limit int, filter EntryFilter) ([]EggCount, error) {
+	entries, _, err := m.ListEntries(ctx, userID, EntryQuery{Filter: filter, Limit: limit})
+	return entries, err
+}
 
-	rows, err := m.DB.Query(ctx, query, userID, limit)
+// UndoEntry marks one of the user's entries as reverted and records a linked
+// reversal row. Undoing an entry that is already reverted changes nothing;
+// changed reports whether anything was written. It returns ErrNoRecord if the
+// entry doesn't exist or belongs to another user, and ErrReversalEntry if the
+// id is a reversal row.
+func (m *EggModel) UndoEntry(ctx context.Context, userID, entryID int) (entry *EggCount, changed bool, err error) {
+	err = pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		entry, err = lockEntry(ctx, tx, userID, entryID)
+		if err != nil || entry.Status == EntryStatusReverted {
+			return err
+		}
+
+		query := `UPDATE eggcount SET status = 'reverted', updated_at = NOW() WHERE id = $1`
+		if _, err := tx.Exec(ctx, query, entry.ID); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO eggcount (user_id, amount, eaten_at, egg_type_id, reverts_entry_id)
+			VALUES ($1, $2, $3, $4, $5)
+		`
+		if _, err := tx.Exec(ctx, query, userID, -entry.Amount, entry.EatenAt, entry.EggTypeID, entry.ID); err != nil {
+			return err
+		}
+
+		entry.Status = EntryStatusReverted
+		changed = true
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	defer rows.Close()
+	return entry, changed, nil
+}
 
-	var entries []EggCount
-	for rows.Next() {
-		var eggCount EggCount
-		if err := rows.Scan(&eggCount.ID, &eggCount.UserID, &eggCount.Amount, &eggCount.CreatedAt); err != nil {
-			return nil, err
+// RedoEntry restores an entry that was undone and marks its reversal row as
+// reverted, keeping both in the audit trail. It mirrors UndoEntry: redoing an
+// active entry changes nothing, and the same errors apply.
+func (m *EggModel) RedoEntry(ctx context.Context, userID, entryID int) (entry *EggCount, changed bool, err error) {
+	err = pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		entry, err = lockEntry(ctx, tx, userID, entryID)
+		if err != nil || entry.Status == EntryStatusActive {
+			return err
 		}
-		entries = append(entries, eggCount)
-	}
 
-	return entries, nil
+		query := `
+			UPDATE eggcount
+			SET status = 'reverted', updated_at = NOW()
+			WHERE reverts_entry_id = $1 AND status = 'active'
+		`
+		if _, err := tx.Exec(ctx, query, entry.ID); err != nil {
+			return err
+		}
+
+		query = `UPDATE eggcount SET status = 'active', updated_at = NOW() WHERE id = $1`
+		if _, err := tx.Exec(ctx, query, entry.ID); err != nil {
+			return err
+		}
+
+		entry.Status = EntryStatusActive
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return entry, changed, nil
 }
 
+// DeleteEntryByID permanently removes an entry along with its reversal trail.
+// Users undo entries with UndoEntry; this is for administrative hard deletes.
 func (m *EggModel) DeleteEntryByID(ctx context.Context, userID int, entryID string) error {
 	query := `
 		DELETE FROM eggcount
@@ -84,27 +269,91 @@ func (m *EggModel) DeleteEntryByID(ctx context.Context, userID int, entryID stri
 	return err
 }
 
-// Get the amount of a specific egg count entry
-func (m *EggModel) GetEntryByID(ctx context.Context, userID, entryID int) (int, error) {
-	var amount int
+// lockEntry loads one of the user's entries FOR UPDATE inside tx.
+func lockEntry(ctx context.Context, tx pgx.Tx, userID, entryID int) (*EggCount, error) {
 	query := `
-		SELECT amount 
-		FROM eggcount 
+		SELECT ` + eggCountColumns + `
+		FROM eggcount
 		WHERE id = $1 AND user_id = $2
+		FOR UPDATE
 	`
-	err := m.DB.QueryRow(ctx, query, entryID, userID).Scan(&amount)
+
+	var entry EggCount
+	err := scanEggCount(tx.QueryRow(ctx, query, entryID, userID), &entry)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	if entry.RevertsEntryID != nil {
+		return nil, ErrReversalEntry
 	}
-	return amount, nil
+	return &entry, nil
 }
 
-// Insert a negative entry to "undo" the previous entry
-func (m *EggModel) InsertNegativeEntry(ctx context.Context, userID, amount int) error {
+// insertEntry adds an entry and its context inside tx, optionally linked to
+// the recipe log it was expanded from. The caller validates e.
+func insertEntry(ctx context.Context, tx pgx.Tx, userID int, e NewEntry, recipeLogID *int) (*EggCount, error) {
 	query := `
-		INSERT INTO eggcount (user_id, amount) 
-		VALUES ($1, $2)
+		INSERT INTO eggcount (user_id, amount, eaten_at, egg_type_id, recipe_log_id)
+		VALUES ($1, $2, $3, COALESCE($4, default_egg_type_id()), $5)
+		RETURNING id
 	`
-	_, err := m.DB.Exec(ctx, query, userID, -amount)
-	return err
+	var entryID int
+	if err := tx.QueryRow(ctx, query, userID, e.Amount, e.EatenAt, e.EggTypeID, recipeLogID).Scan(&entryID); err != nil {
+		return nil, err
+	}
+
+	var tags *[]string
+	if len(e.Tags) > 0 {
+		tags = &e.Tags
+	}
+	if err := saveEntryContext(ctx, tx, userID, entryID, nullIfEmpty(e.Meal), nullIfEmpty(e.Note), tags); err != nil {
+		return nil, err
+	}
+
+	return loadEntry(ctx, tx, entryID)
+}
+
+// loadEntry reads an entry inside tx, seeing context written earlier in it.
+func loadEntry(ctx context.Context, tx pgx.Tx, entryID int) (*EggCount, error) {
+	query := `
+		SELECT ` + eggCountColumns + `
+		FROM eggcount
+		WHERE id = $1
+	`
+
+	var entry EggCount
+	if err := scanEggCount(tx.QueryRow(ctx, query, entryID), &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// nullIfEmpty maps an empty string to a SQL NULL.
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func scanEggCount(row pgx.Row, entry *EggCount) error {
+	return row.Scan(
+		&entry.ID,
+		&entry.UserID,
+		&entry.Amount,
+		&entry.EatenAt,
+		&entry.EggTypeID,
+		&entry.EggType,
+		&entry.Status,
+		&entry.RevertsEntryID,
+		&entry.CreatedAt,
+		&entry.UpdatedAt,
+		&entry.Meal,
+		&entry.Note,
+		&entry.Tags,
+		&entry.RecipeID,
+	)
 }
@@ -0,0 +1,224 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EggType is an entry in the egg type catalog. Built-in types have a Slug and
+// are shared by everyone; custom types belong to the user who created them.
+type EggType struct {
+	ID        int       `json:"id"`
+	Slug      *string   `json:"slug"`
+	Species   string    `json:"species"`
+	Size      string    `json:"size"`
+	Name      string    `json:"name"`
+	Custom    bool      `json:"custom"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TypeTotal is the number of eggs of one type a user has eaten.
+type TypeTotal struct {
+	EggTypeID int    `json:"egg_type_id"`
+	Name      string `json:"name"`
+	Species   string `json:"species"`
+	Size      string `json:"size"`
+	Total     int    `json:"total"`
+}
+
+// EggTypeModel handles database operations for the egg_types table.
+type EggTypeModel struct {
+	DB *pgxpool.Pool
+}
+
+// NewEggTypeModel creates a new instance of EggTypeModel.
+func NewEggTypeModel(db *pgxpool.Pool) *EggTypeModel {
+	return &EggTypeModel{DB: db}
+}
+
+// ListEggTypes retrieves the built-in types followed by the user's own.
+func (m *EggTypeModel) ListEggTypes(ctx context.Context, userID int) ([]EggType, error) {
+	query := `
+		SELECT id, slug, species, size, name, user_id IS NOT NULL, created_at
+		FROM egg_types
+		WHERE user_id IS NULL OR user_id = $1
+		ORDER BY user_id NULLS FIRST, species, id
+	`
+	rows, err := m.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying egg types: %v", err)
+	}
+	defer rows.Close()
+
+	types := []EggType{}
+	for rows.Next() {
+		var t EggType
+		err := rows.Scan(&t.ID, &t.Slug, &t.Species, &t.Size, &t.Name, &t.Custom, &t.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning egg type: %v", err)
+		}
+		types = append(types, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return types, nil
+}
+
+// CreateEggType adds a custom type for the user, along with its per-egg
+// nutrition when given. Both are saved in one transaction. It returns
+// ErrDuplicateEggType if the user can already see a type with the same
+// species and size.
+func (m *EggTypeModel) CreateEggType(ctx context.Context, userID int, species, size, name string, nutrition *Nutrition) (*EggType, error) {
+	var t EggType
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		var exists bool
+		query := `
+			SELECT EXISTS (
+				SELECT 1 FROM egg_types
+				WHERE (user_id IS NULL OR user_id = $1)
+				  AND LOWER(species) = LOWER($2)
+				  AND LOWER(size) = LOWER($3)
+			)
+		`
+		if err := tx.QueryRow(ctx, query, userID, species, size).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrDuplicateEggType
+		}
+
+		query = `
+			INSERT INTO egg_types (user_id, species, size, name)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, slug, species, size, name, TRUE, created_at
+		`
+		err := tx.QueryRow(ctx, query, userID, species, size, name).Scan(
+			&t.ID, &t.Slug, &t.Species, &t.Size, &t.Name, &t.Custom, &t.CreatedAt,
+		)
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return ErrDuplicateEggType
+		}
+		if err != nil {
+			return err
+		}
+
+		if nutrition != nil {
+			return setNutrition(ctx, tx, t.ID, *nutrition)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// SetNutrition replaces the per-egg nutrition of one of the user's custom
+// types. It returns ErrNoRecord if the type isn't theirs; built-in types
+// can't be changed.
+func (m *EggTypeModel) SetNutrition(ctx context.Context, userID, eggTypeID int, n Nutrition) error {
+	return pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		var owned bool
+		query := `
+			SELECT EXISTS (
+				SELECT 1 FROM egg_types
+				WHERE id = $1 AND user_id = $2
+				FOR UPDATE
+			)
+		`
+		if err := tx.QueryRow(ctx, query, eggTypeID, userID).Scan(&owned); err != nil {
+			return err
+		}
+		if !owned {
+			return ErrNoRecord
+		}
+
+		return setNutrition(ctx, tx, eggTypeID, n)
+	})
+}
+
+// DeleteEggType removes one of the user's custom types. It returns
+// ErrNoRecord if the type isn't theirs and ErrEggTypeInUse if entries still
+// refer to it.
+func (m *EggTypeModel) DeleteEggType(ctx context.Context, userID, eggTypeID int) error {
+	query := `
+		DELETE FROM egg_types
+		WHERE id = $1 AND user_id = $2
+	`
+	tag, err := m.DB.Exec(ctx, query, eggTypeID, userID)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		return ErrEggTypeInUse
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// GetTotalsByType retrieves how many eggs of each type the user has eaten.
+func (m *EggTypeModel) GetTotalsByType(ctx context.Context, userID int) ([]TypeTotal, error) {
+	query := `
+		SELECT egg_types.id, egg_types.name, egg_types.species, egg_types.size, SUM(eggcount.amount)
+		FROM eggcount
+		JOIN egg_types ON egg_types.id = eggcount.egg_type_id
+		WHERE eggcount.user_id = $1 AND ` + countedEntries + `
+		GROUP BY egg_types.id
+		ORDER BY SUM(eggcount.amount) DESC, egg_types.id
+	`
+	rows, err := m.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying totals by type: %v", err)
+	}
+	defer rows.Close()
+
+	totals := []TypeTotal{}
+	for rows.Next() {
+		var t TypeTotal
+		if err := rows.Scan(&t.EggTypeID, &t.Name, &t.Species, &t.Size, &t.Total); err != nil {
+			return nil, fmt.Errorf("error scanning type total: %v", err)
+		}
+		totals = append(totals, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+// checkEggType returns ErrUnknownEggType unless eggTypeID is a built-in type
+// or one of the user's own.
+func checkEggType(ctx context.Context, db *pgxpool.Pool, userID, eggTypeID int) error {
+	var visible bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM egg_types
+			WHERE id = $1 AND (user_id IS NULL OR user_id = $2)
+		)
+	`
+	if err := db.QueryRow(ctx, query, eggTypeID, userID).Scan(&visible); err != nil {
+		return err
+	}
+	if !visible {
+		return ErrUnknownEggType
+	}
+	return nil
+}
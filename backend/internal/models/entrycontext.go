@@ -0,0 +1,204 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Meals an entry can be logged against.
+const (
+	MealBreakfast = "breakfast"
+	MealLunch     = "lunch"
+	MealDinner    = "dinner"
+	MealSnack     = "snack"
+)
+
+// Limits on the context attached to an entry.
+const (
+	MaxNoteLength   = 1000
+	MaxTagLength    = 50
+	MaxTagsPerEntry = 10
+)
+
+// ValidMeal reports whether meal is one of the Meal constants.
+func ValidMeal(meal string) bool {
+	switch meal {
+	case MealBreakfast, MealLunch, MealDinner, MealSnack:
+		return true
+	}
+	return false
+}
+
+// Tag is a user-defined label for entries.
+type Tag struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Entries   int       `json:"entries"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagModel handles database operations for the tags table.
+type TagModel struct {
+	DB *pgxpool.Pool
+}
+
+// NewTagModel creates a new instance of TagModel.
+func NewTagModel(db *pgxpool.Pool) *TagModel {
+	return &TagModel{DB: db}
+}
+
+// ListTags retrieves the user's tags with how many counted entries use each.
+func (m *TagModel) ListTags(ctx context.Context, userID int) ([]Tag, error) {
+	query := `
+		SELECT tags.id, tags.name, COUNT(eggcount.id), tags.created_at
+		FROM tags
+		LEFT JOIN entry_tags ON entry_tags.tag_id = tags.id
+		LEFT JOIN eggcount ON eggcount.id = entry_tags.entry_id AND ` + countedEntries + `
+		WHERE tags.user_id = $1
+		GROUP BY tags.id
+		ORDER BY LOWER(tags.name)
+	`
+	rows, err := m.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying tags: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.Entries, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning tag: %v", err)
+		}
+		tags = append(tags, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// DeleteTag removes one of the user's tags from every entry. It returns
+// ErrNoRecord if the tag isn't theirs.
+func (m *TagModel) DeleteTag(ctx context.Context, userID, tagID int) error {
+	query := `
+		DELETE FROM tags
+		WHERE id = $1 AND user_id = $2
+	`
+	tag, err := m.DB.Exec(ctx, query, tagID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// NormalizeTags trims tag names and drops blanks and case-insensitive
+// duplicates, keeping the first spelling of each.
+func NormalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// saveEntryContext writes the meal, note and tags of an entry inside tx. Nil
+// arguments are left unchanged; an empty meal or note clears it and an empty
+// tag list removes all tags. Tags the user hasn't used before are created.
+func saveEntryContext(ctx context.Context, tx pgx.Tx, userID, entryID int, meal, note *string, tags *[]string) error {
+	if meal != nil || note != nil {
+		query := `
+			INSERT INTO entry_context (entry_id, meal, note)
+			VALUES ($1, NULLIF($2, ''), NULLIF($3, ''))
+			ON CONFLICT (entry_id) DO UPDATE
+			SET meal = CASE WHEN $2::text IS NULL THEN entry_context.meal ELSE EXCLUDED.meal END,
+				note = CASE WHEN $3::text IS NULL THEN entry_context.note ELSE EXCLUDED.note END,
+				updated_at = NOW()
+		`
+		if _, err := tx.Exec(ctx, query, entryID, meal, note); err != nil {
+			return err
+		}
+
+		query = `DELETE FROM entry_context WHERE entry_id = $1 AND meal IS NULL AND note IS NULL`
+		if _, err := tx.Exec(ctx, query, entryID); err != nil {
+			return err
+		}
+	}
+
+	if tags == nil {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM entry_tags WHERE entry_id = $1`, entryID); err != nil {
+		return err
+	}
+	if len(*tags) == 0 {
+		return nil
+	}
+
+	tagIDs, err := ensureTags(ctx, tx, userID, *tags)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO entry_tags (entry_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	for _, tagID := range tagIDs {
+		if _, err := tx.Exec(ctx, query, entryID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureTags creates any of the named tags the user doesn't have yet and
+// returns the id of each, keyed by the name as given.
+func ensureTags(ctx context.Context, tx pgx.Tx, userID int, names []string) (map[string]int, error) {
+	query := `
+		INSERT INTO tags (user_id, name)
+		SELECT $1, name FROM UNNEST($2::text[]) AS name
+		ON CONFLICT (user_id, LOWER(name)) DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, query, userID, names); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT input.name, tags.id
+		FROM UNNEST($2::text[]) AS input (name)
+		JOIN tags ON tags.user_id = $1 AND LOWER(tags.name) = LOWER(input.name)
+	`
+	rows, err := tx.Query(ctx, query, userID, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tagIDs := map[string]int{}
+	for rows.Next() {
+		var name string
+		var id int
+		if err := rows.Scan(&name, &id); err != nil {
+			return nil, err
+		}
+		tagIDs[name] = id
+	}
+
+	return tagIDs, rows.Err()
+}
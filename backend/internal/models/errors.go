@@ -0,0 +1,83 @@
+package models
+
+import "errors"
+
+// Postgres error codes that models translate into the errors below.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+var (
+	// ErrNoRecord is returned when a lookup matches no row.
+	ErrNoRecord = errors.New("models: no matching record found")
+
+	// ErrInvalidCredentials is returned when a password doesn't match.
+	ErrInvalidCredentials = errors.New("models: invalid credentials")
+
+	// ErrDuplicateEmail is returned when an email address is already used by
+	// another account.
+	ErrDuplicateEmail = errors.New("models: email address already in use")
+
+	// ErrInvalidCode is returned when a two-factor or recovery code is wrong
+	// or has already been used.
+	ErrInvalidCode = errors.New("models: invalid or used code")
+
+	// ErrTwoFactorEnabled is returned when enrolling in two-factor
+	// authentication while it is already on.
+	ErrTwoFactorEnabled = errors.New("models: two-factor authentication already enabled")
+
+	// ErrForbidden is returned when a row exists but the user is not allowed to act on it.
+	ErrForbidden = errors.New("models: action not permitted for this user")
+
+	// ErrSelfRequest is returned when a user tries to befriend or block themselves.
+	ErrSelfRequest = errors.New("models: cannot target your own account")
+
+	// ErrDuplicateRequest is returned when a pending request already exists
+	// between two users, in either direction.
+	ErrDuplicateRequest = errors.New("models: a pending friend request already exists")
+
+	// ErrAlreadyFriends is returned when sending a request to an existing friend.
+	ErrAlreadyFriends = errors.New("models: users are already friends")
+
+	// ErrBlocked is returned when either user has blocked the other.
+	ErrBlocked = errors.New("models: user is blocked")
+
+	// ErrEatenAtInFuture is returned when an egg entry is timestamped in the future.
+	ErrEatenAtInFuture = errors.New("models: eaten_at cannot be in the future")
+
+	// ErrReversalEntry is returned when trying to undo or redo the reversal
+	// row of an undo rather than the entry itself.
+	ErrReversalEntry = errors.New("models: reversal entries cannot be undone or redone")
+
+	// ErrUnknownEggType is returned when an entry refers to an egg type that
+	// doesn't exist or belongs to another user.
+	ErrUnknownEggType = errors.New("models: unknown egg type")
+
+	// ErrDuplicateEggType is returned when creating a type that already exists.
+	ErrDuplicateEggType = errors.New("models: egg type already exists")
+
+	// ErrEggTypeInUse is returned when deleting a type that entries or
+	// recipes still use.
+	ErrEggTypeInUse = errors.New("models: egg type is in use")
+
+	// ErrInvalidCursor is returned for a malformed cursor, or one issued for
+	// the other sort order.
+	ErrInvalidCursor = errors.New("models: invalid cursor")
+
+	// ErrTakeoutInProgress is returned when requesting a takeout while another
+	// is still being built.
+	ErrTakeoutInProgress = errors.New("models: a takeout is already in progress")
+
+	// ErrTakeoutReclaimed is returned when finishing a takeout whose claim
+	// has gone stale and been taken over by another worker.
+	ErrTakeoutReclaimed = errors.New("models: takeout was reclaimed by another worker")
+
+	// ErrTokenLimit is returned when a user has already been sent as many
+	// tokens of a purpose as the limit allows.
+	ErrTokenLimit = errors.New("models: too many tokens requested")
+
+	// ErrDuplicateRecipe is returned when the user already has a recipe with
+	// the same name.
+	ErrDuplicateRecipe = errors.New("models: recipe already exists")
+)
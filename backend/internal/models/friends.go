@@ -2,122 +2,419 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// Friendship statuses stored in friends.status.
+const (
+	FriendStatusPending  = "pending"
+	FriendStatusAccepted = "accepted"
+	FriendStatusRejected = "rejected"
+)
+
+// Friend is the other user in a friendship, as seen by the requesting user.
+// ID is the friendship row id, which is what accept/reject take.
 type Friend struct {
 	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
 	Username  string    `json:"username"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
 	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// BlockedUser is a user the requesting user has blocked.
+type BlockedUser struct {
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FriendModel handles database operations for the friends table.
+//
+// A row is a request from from_user_id to to_user_id. Once accepted the
+// friendship is symmetric: both users see each other in GetFriendsList.
+//
+// Users whose accounts are scheduled for deletion are treated as gone: they
+// are left out of friend and request lists and can't be sent or answer
+// requests. Their rows are kept, so everything reappears if the deletion is
+// cancelled. GetBlockedUsers still lists them so a block can be lifted.
 type FriendModel struct {
 	DB *pgxpool.Pool
 }
 
-// AddFriendRequest adds a friend request to the database
-// SendFriendRequest adds a friend request to the database
+// NewFriendModel creates a new instance of FriendModel.
+func NewFriendModel(db *pgxpool.Pool) *FriendModel {
+	return &FriendModel{DB: db}
+}
+
+// SendFriendRequest creates a pending friend request from one user to another.
+// It returns ErrSelfRequest, ErrBlocked, ErrDuplicateRequest or
+// ErrAlreadyFriends when the request is not allowed, and ErrNoRecord if the
+// receiving user does not exist or is scheduled for deletion.
 func (m *FriendModel) SendFriendRequest(ctx context.Context, fromUserID, toUserID int) error {
+	if fromUserID == toUserID {
+		return ErrSelfRequest
+	}
+
+	blocked, err := m.isBlockedEitherWay(ctx, fromUserID, toUserID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+
+	var status string
 	query := `
-		INSERT INTO friends (from_user_id, to_user_id, status, created_at) 
-		VALUES ($1, $2, 'pending', NOW())
+		SELECT status
+		FROM friends
+		WHERE LEAST(from_user_id, to_user_id) = LEAST($1::int, $2::int)
+		  AND GREATEST(from_user_id, to_user_id) = GREATEST($1::int, $2::int)
+		  AND status IN ('pending', 'accepted')
 	`
-	_, err := m.DB.Exec(ctx, query, fromUserID, toUserID)
-	return err
-}
+	err = m.DB.QueryRow(ctx, query, fromUserID, toUserID).Scan(&status)
+	switch {
+	case err == nil && status == FriendStatusAccepted:
+		return ErrAlreadyFriends
+	case err == nil:
+		return ErrDuplicateRequest
+	case !errors.Is(err, pgx.ErrNoRows):
+		return err
+	}
+
+	query = `
+		INSERT INTO friends (from_user_id, to_user_id, status)
+		SELECT $1, $2, 'pending'
+		WHERE EXISTS (SELECT 1 FROM users WHERE id = $2 AND deletion_requested_at IS NULL)
+	`
+	tag, err := m.DB.Exec(ctx, query, fromUserID, toUserID)
 
-// eggcounter/backend/internal/models/friends.go
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			// Lost a race with a concurrent request for the same pair.
+			return ErrDuplicateRequest
+		case pgForeignKeyViolation:
+			return ErrNoRecord
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
 
-// File: internal/models/friends.go
-func (m *FriendModel) GetFriendsList(ctx context.Context, userID int) ([]User, error) {
+// GetFriendsList retrieves every accepted friend of the user, whichever side
+// sent the original request.
+func (m *FriendModel) GetFriendsList(ctx context.Context, userID int) ([]Friend, error) {
 	query := `
-		SELECT 
-			users.id, 
-			users.username, 
-			users.email, 
-			friends.created_at 
-		FROM friends 
-		JOIN users ON friends.friend_id = users.id 
-		WHERE friends.user_id = $1 
+		SELECT
+			friends.id,
+			users.id,
+			users.username,
+			users.first_name,
+			users.last_name,
+			friends.status,
+			friends.created_at
+		FROM friends
+		JOIN users ON users.id = CASE
+			WHEN friends.from_user_id = $1 THEN friends.to_user_id
+			ELSE friends.from_user_id
+		END
+		WHERE (friends.from_user_id = $1 OR friends.to_user_id = $1)
 		  AND friends.status = 'accepted'
+		  AND users.deletion_requested_at IS NULL
+		ORDER BY users.username
 	`
 	rows, err := m.DB.Query(ctx, query, userID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error querying friends list: %v", err)
 	}
-	defer rows.Close()
 
-	var friends []User
-	for rows.Next() {
-		var friend User
-		err := rows.Scan(&friend.ID, &friend.Username, &friend.Email, &friend.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		friends = append(friends, friend)
+	return scanFriends(rows)
+}
+
+// GetFriendRequests retrieves the pending requests other users have sent to the user.
+func (m *FriendModel) GetFriendRequests(ctx context.Context, userID int) ([]Friend, error) {
+	query := `
+		SELECT
+			friends.id,
+			users.id,
+			users.username,
+			users.first_name,
+			users.last_name,
+			friends.status,
+			friends.created_at
+		FROM friends
+		JOIN users ON users.id = friends.from_user_id
+		WHERE friends.to_user_id = $1
+		  AND friends.status = 'pending'
+		  AND users.deletion_requested_at IS NULL
+		ORDER BY friends.created_at DESC
+	`
+	rows, err := m.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying friend requests: %v", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+	return scanFriends(rows)
+}
+
+// GetSentFriendRequests retrieves the pending requests the user has sent that
+// have not been answered yet.
+func (m *FriendModel) GetSentFriendRequests(ctx context.Context, userID int) ([]Friend, error) {
+	query := `
+		SELECT
+			friends.id,
+			users.id,
+			users.username,
+			users.first_name,
+			users.last_name,
+			friends.status,
+			friends.created_at
+		FROM friends
+		JOIN users ON users.id = friends.to_user_id
+		WHERE friends.from_user_id = $1
+		  AND friends.status = 'pending'
+		  AND users.deletion_requested_at IS NULL
+		ORDER BY friends.created_at DESC
+	`
+	rows, err := m.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying sent friend requests: %v", err)
 	}
 
-	return friends, nil
+	return scanFriends(rows)
+}
+
+// AcceptFriendRequest accepts a pending friend request addressed to userID.
+// It returns ErrNoRecord if there is no such pending request and ErrForbidden
+// if the request was sent to somebody else.
+func (m *FriendModel) AcceptFriendRequest(ctx context.Context, userID, requestID int) error {
+	return m.respondToRequest(ctx, userID, requestID, FriendStatusAccepted)
 }
 
-// eggcounter/backend/internal/models/friends.go
+// RejectFriendRequest rejects a pending friend request addressed to userID,
+// with the same errors as AcceptFriendRequest.
+func (m *FriendModel) RejectFriendRequest(ctx context.Context, userID, requestID int) error {
+	return m.respondToRequest(ctx, userID, requestID, FriendStatusRejected)
+}
 
-func (m *FriendModel) AcceptFriendRequest(ctx context.Context, friendID string) error {
+func (m *FriendModel) respondToRequest(ctx context.Context, userID, requestID int, status string) error {
 	query := `
-		UPDATE friends 
-		SET status = 'accepted' 
-		WHERE id = $1
+		UPDATE friends
+		SET status = $3, responded_at = NOW()
+		WHERE id = $1 AND to_user_id = $2 AND status = 'pending'
+		  AND EXISTS (
+			SELECT 1 FROM users
+			WHERE users.id = friends.from_user_id AND users.deletion_requested_at IS NULL
+		  )
 	`
-	_, err := m.DB.Exec(ctx, query, friendID)
-	return err
+	tag, err := m.DB.Exec(ctx, query, requestID, userID, status)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+
+	return m.pendingRequestError(ctx, requestID)
 }
 
-func (m *FriendModel) RejectFriendRequest(ctx context.Context, friendID string) error {
+// CancelFriendRequest withdraws a pending request that userID sent. It returns
+// ErrNoRecord if there is no such pending request and ErrForbidden if somebody
+// else sent it.
+func (m *FriendModel) CancelFriendRequest(ctx context.Context, userID, requestID int) error {
 	query := `
-		UPDATE friends 
-		SET status = 'rejected' 
-		WHERE id = $1
+		DELETE FROM friends
+		WHERE id = $1 AND from_user_id = $2 AND status = 'pending'
 	`
-	_, err := m.DB.Exec(ctx, query, friendID)
+	tag, err := m.DB.Exec(ctx, query, requestID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+
+	return m.pendingRequestError(ctx, requestID)
+}
+
+// RemoveFriend ends an accepted friendship between userID and friendID. It
+// returns ErrNoRecord if the two users are not friends.
+func (m *FriendModel) RemoveFriend(ctx context.Context, userID, friendID int) error {
+	query := `
+		DELETE FROM friends
+		WHERE LEAST(from_user_id, to_user_id) = LEAST($1::int, $2::int)
+		  AND GREATEST(from_user_id, to_user_id) = GREATEST($1::int, $2::int)
+		  AND status = 'accepted'
+	`
+	tag, err := m.DB.Exec(ctx, query, userID, friendID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// BlockUser blocks blockedID on behalf of userID. Any friendship or pending
+// request between them is removed, and neither can send the other a request
+// until the block is lifted. Blocking an already blocked user is a no-op.
+func (m *FriendModel) BlockUser(ctx context.Context, userID, blockedID int) error {
+	if userID == blockedID {
+		return ErrSelfRequest
+	}
+
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO user_blocks (blocker_id, blocked_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`
+		if _, err := tx.Exec(ctx, query, userID, blockedID); err != nil {
+			return err
+		}
+
+		query = `
+			DELETE FROM friends
+			WHERE LEAST(from_user_id, to_user_id) = LEAST($1::int, $2::int)
+			  AND GREATEST(from_user_id, to_user_id) = GREATEST($1::int, $2::int)
+			  AND status IN ('pending', 'accepted')
+		`
+		_, err := tx.Exec(ctx, query, userID, blockedID)
+		return err
+	})
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		return ErrNoRecord
+	}
 	return err
 }
 
-func (m *FriendModel) GetFriendRequests(ctx context.Context, userID int) ([]User, error) {
+// UnblockUser lifts a block. It returns ErrNoRecord if userID had not blocked blockedID.
+func (m *FriendModel) UnblockUser(ctx context.Context, userID, blockedID int) error {
 	query := `
-		SELECT 
-			users.id, 
-			users.username, 
-			users.email, 
-			friends.created_at 
-		FROM friends 
-		JOIN users ON friends.user_id = users.id 
-		WHERE friends.friend_id = $1 
-		  AND friends.status = 'pending'
+		DELETE FROM user_blocks
+		WHERE blocker_id = $1 AND blocked_id = $2
 	`
+	tag, err := m.DB.Exec(ctx, query, userID, blockedID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
 
+// GetBlockedUsers retrieves the users that userID has blocked.
+func (m *FriendModel) GetBlockedUsers(ctx context.Context, userID int) ([]BlockedUser, error) {
+	query := `
+		SELECT users.id, users.username, users.first_name, users.last_name, user_blocks.created_at
+		FROM user_blocks
+		JOIN users ON users.id = user_blocks.blocked_id
+		WHERE user_blocks.blocker_id = $1
+		ORDER BY users.username
+	`
 	rows, err := m.DB.Query(ctx, query, userID)
 	if err != nil {
-		return nil, fmt.Errorf("error querying friend requests: %v", err)
+		return nil, fmt.Errorf("error querying blocked users: %v", err)
+	}
+	defer rows.Close()
+
+	blocked := []BlockedUser{}
+	for rows.Next() {
+		var user BlockedUser
+		err := rows.Scan(&user.UserID, &user.Username, &user.FirstName, &user.LastName, &user.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning blocked user: %v", err)
+		}
+		blocked = append(blocked, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return blocked, nil
+}
+
+// pendingRequestError explains why an update scoped to a user matched no
+// pending request: either it doesn't exist, its sender is scheduled for
+// deletion, or it belongs to someone else.
+func (m *FriendModel) pendingRequestError(ctx context.Context, requestID int) error {
+	var exists bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM friends
+			JOIN users ON users.id = friends.from_user_id
+			WHERE friends.id = $1 AND friends.status = 'pending'
+			  AND users.deletion_requested_at IS NULL
+		)
+	`
+	if err := m.DB.QueryRow(ctx, query, requestID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
 	}
+	return ErrForbidden
+}
+
+func (m *FriendModel) isBlockedEitherWay(ctx context.Context, userA, userB int) (bool, error) {
+	var blocked bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2)
+			   OR (blocker_id = $2 AND blocked_id = $1)
+		)
+	`
+	err := m.DB.QueryRow(ctx, query, userA, userB).Scan(&blocked)
+	return blocked, err
+}
+
+func scanFriends(rows pgx.Rows) ([]Friend, error) {
 	defer rows.Close()
 
-	var friendRequests []User
+	friends := []Friend{}
 	for rows.Next() {
-		var user User
-		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt)
+		var friend Friend
+		err := rows.Scan(
+			&friend.ID,
+			&friend.UserID,
+			&friend.Username,
+			&friend.FirstName,
+			&friend.LastName,
+			&friend.Status,
+			&friend.CreatedAt,
+		)
 		if err != nil {
-			return nil, fmt.Errorf("error scanning friend request: %v", err)
+			return nil, fmt.Errorf("error scanning friend: %v", err)
 		}
-		friendRequests = append(friendRequests, user)
+		friends = append(friends, friend)
 	}
 
-	return friendRequests, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return friends, nil
 }
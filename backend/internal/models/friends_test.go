@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestFriendRequestLifecycle(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	m := NewFriendModel(db)
+
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+
+	// Send, then the recipient sees it pending.
+	if err := m.SendFriendRequest(ctx, alice, bob); err != nil {
+		t.Fatalf("SendFriendRequest: %v", err)
+	}
+
+	requests, err := m.GetFriendRequests(ctx, bob)
+	if err != nil {
+		t.Fatalf("GetFriendRequests: %v", err)
+	}
+	if len(requests) != 1 || requests[0].UserID != alice || requests[0].Status != FriendStatusPending {
+		t.Fatalf("GetFriendRequests(bob) = %+v, want one pending request from alice", requests)
+	}
+
+	// Only the recipient can accept.
+	if err := m.AcceptFriendRequest(ctx, alice, requests[0].ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("AcceptFriendRequest by the sender = %v, want ErrForbidden", err)
+	}
+
+	// Accept, then both users see each other.
+	if err := m.AcceptFriendRequest(ctx, bob, requests[0].ID); err != nil {
+		t.Fatalf("AcceptFriendRequest: %v", err)
+	}
+
+	for _, c := range []struct {
+		name           string
+		userID, friend int
+	}{
+		{"alice", alice, bob},
+		{"bob", bob, alice},
+	} {
+		friends, err := m.GetFriendsList(ctx, c.userID)
+		if err != nil {
+			t.Fatalf("GetFriendsList(%s): %v", c.name, err)
+		}
+		if len(friends) != 1 || friends[0].UserID != c.friend || friends[0].Status != FriendStatusAccepted {
+			t.Errorf("GetFriendsList(%s) = %+v, want the other user as an accepted friend", c.name, friends)
+		}
+	}
+
+	requests, err = m.GetFriendRequests(ctx, bob)
+	if err != nil {
+		t.Fatalf("GetFriendRequests after accept: %v", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("GetFriendRequests(bob) after accept = %+v, want none", requests)
+	}
+}
+
+func TestFriendRequestDuplicates(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	m := NewFriendModel(db)
+
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+
+	if err := m.SendFriendRequest(ctx, alice, bob); err != nil {
+		t.Fatalf("SendFriendRequest: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to int
+	}{
+		{"duplicate", alice, bob},
+		{"reverse", bob, alice},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.SendFriendRequest(ctx, tt.from, tt.to); !errors.Is(err, ErrDuplicateRequest) {
+				t.Errorf("SendFriendRequest = %v, want ErrDuplicateRequest", err)
+			}
+
+			// The index rejects the pair even without the model's own check,
+			// which is what catches concurrent requests.
+			_, err := db.Exec(ctx, `INSERT INTO friends (from_user_id, to_user_id, status) VALUES ($1, $2, 'pending')`, tt.from, tt.to)
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) || pgErr.Code != pgUniqueViolation || pgErr.ConstraintName != "friends_pair_live_idx" {
+				t.Errorf("direct insert error = %v, want unique violation on friends_pair_live_idx", err)
+			}
+		})
+	}
+}
+
+func TestFriendRequestBlocking(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	m := NewFriendModel(db)
+
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+
+	if err := m.SendFriendRequest(ctx, alice, bob); err != nil {
+		t.Fatalf("SendFriendRequest: %v", err)
+	}
+	if err := m.BlockUser(ctx, bob, alice); err != nil {
+		t.Fatalf("BlockUser: %v", err)
+	}
+
+	// Blocking removes the pending request.
+	requests, err := m.GetFriendRequests(ctx, bob)
+	if err != nil {
+		t.Fatalf("GetFriendRequests: %v", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("GetFriendRequests(bob) after block = %+v, want none", requests)
+	}
+
+	// Neither side can send a request while the block stands.
+	if err := m.SendFriendRequest(ctx, alice, bob); !errors.Is(err, ErrBlocked) {
+		t.Errorf("SendFriendRequest to blocker = %v, want ErrBlocked", err)
+	}
+	if err := m.SendFriendRequest(ctx, bob, alice); !errors.Is(err, ErrBlocked) {
+		t.Errorf("SendFriendRequest from blocker = %v, want ErrBlocked", err)
+	}
+
+	if err := m.UnblockUser(ctx, bob, alice); err != nil {
+		t.Fatalf("UnblockUser: %v", err)
+	}
+	if err := m.SendFriendRequest(ctx, alice, bob); err != nil {
+		t.Errorf("SendFriendRequest after unblock = %v, want nil", err)
+	}
+}
+
+func TestFriendsHideAccountsPendingDeletion(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	m := NewFriendModel(db)
+	users := NewUserModel(db)
+
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	carol := createTestUser(t, db, "carol")
+
+	if err := m.SendFriendRequest(ctx, alice, bob); err != nil {
+		t.Fatalf("SendFriendRequest: %v", err)
+	}
+	requests, err := m.GetFriendRequests(ctx, bob)
+	if err != nil || len(requests) != 1 {
+		t.Fatalf("GetFriendRequests(bob) = %+v, %v, want one request", requests, err)
+	}
+	if err := m.AcceptFriendRequest(ctx, bob, requests[0].ID); err != nil {
+		t.Fatalf("AcceptFriendRequest: %v", err)
+	}
+	if err := m.SendFriendRequest(ctx, carol, alice); err != nil {
+		t.Fatalf("SendFriendRequest: %v", err)
+	}
+	pending, err := m.GetFriendRequests(ctx, alice)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("GetFriendRequests(alice) = %+v, %v, want one request", pending, err)
+	}
+
+	for _, id := range []int{bob, carol} {
+		if _, err := users.ScheduleDeletion(ctx, id, time.Hour); err != nil {
+			t.Fatalf("ScheduleDeletion: %v", err)
+		}
+	}
+
+	if _, err := users.GetUserByUsername(ctx, "bob"); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("GetUserByUsername(bob) = %v, want ErrNoRecord", err)
+	}
+	if friends, err := m.GetFriendsList(ctx, alice); err != nil || len(friends) != 0 {
+		t.Errorf("GetFriendsList(alice) = %+v, %v, want none", friends, err)
+	}
+	if requests, err := m.GetFriendRequests(ctx, alice); err != nil || len(requests) != 0 {
+		t.Errorf("GetFriendRequests(alice) = %+v, %v, want none", requests, err)
+	}
+	if err := m.AcceptFriendRequest(ctx, alice, pending[0].ID); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("AcceptFriendRequest from a deleting user = %v, want ErrNoRecord", err)
+	}
+	if err := m.SendFriendRequest(ctx, alice, carol); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("SendFriendRequest to a deleting user = %v, want ErrNoRecord", err)
+	}
+
+	// Cancelling the deletion brings the friendship back.
+	if _, err := users.CancelDeletion(ctx, bob); err != nil {
+		t.Fatalf("CancelDeletion: %v", err)
+	}
+	if friends, err := m.GetFriendsList(ctx, alice); err != nil || len(friends) != 1 || friends[0].UserID != bob {
+		t.Errorf("GetFriendsList(alice) after cancel = %+v, %v, want bob", friends, err)
+	}
+}
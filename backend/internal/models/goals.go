@@ -0,0 +1,154 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Limit names reported by GoalProgress.Exceeded.
+const (
+	LimitDailyMax  = "daily_max"
+	LimitWeeklyMax = "weekly_max"
+)
+
+// Goals are a user's intake targets. A nil field means no goal of that kind.
+type Goals struct {
+	DailyMax  *int       `json:"daily_max"`
+	WeeklyMax *int       `json:"weekly_max"`
+	DailyMin  *int       `json:"daily_min"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// GoalProgress compares the totals for the day in Date, usually today, and
+// the week containing it against the user's goals. Remaining counts are nil
+// when there is no matching limit, and DailyMinMet is nil when there is no
+// daily minimum.
+type GoalProgress struct {
+	Goals           Goals    `json:"goals"`
+	Date            string   `json:"date"`
+	DayTotal        int      `json:"day_total"`
+	WeekTotal       int      `json:"week_total"`
+	DailyRemaining  *int     `json:"daily_remaining"`
+	WeeklyRemaining *int     `json:"weekly_remaining"`
+	DailyMinMet     *bool    `json:"daily_min_met"`
+	Exceeded        []string `json:"exceeded"`
+}
+
+// IsExceeded reports whether the named limit is exceeded.
+func (p *GoalProgress) IsExceeded(limit string) bool {
+	return slices.Contains(p.Exceeded, limit)
+}
+
+// GoalModel handles database operations for the user_goals table.
+type GoalModel struct {
+	DB *pgxpool.Pool
+}
+
+// NewGoalModel creates a new instance of GoalModel.
+func NewGoalModel(db *pgxpool.Pool) *GoalModel {
+	return &GoalModel{DB: db}
+}
+
+// GetGoals retrieves the user's goals. Users who never set any get an empty Goals.
+func (m *GoalModel) GetGoals(ctx context.Context, userID int) (*Goals, error) {
+	var goals Goals
+
+	query := `
+		SELECT daily_max, weekly_max, daily_min, updated_at
+		FROM user_goals
+		WHERE user_id = $1
+	`
+	err := m.DB.QueryRow(ctx, query, userID).Scan(
+		&goals.DailyMax,
+		&goals.WeeklyMax,
+		&goals.DailyMin,
+		&goals.UpdatedAt,
+	)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	return &goals, nil
+}
+
+// SaveGoals replaces the user's goals. Callers validate the values first.
+func (m *GoalModel) SaveGoals(ctx context.Context, userID int, goals Goals) (*Goals, error) {
+	query := `
+		INSERT INTO user_goals (user_id, daily_max, weekly_max, daily_min)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET daily_max = EXCLUDED.daily_max,
+			weekly_max = EXCLUDED.weekly_max,
+			daily_min = EXCLUDED.daily_min,
+			updated_at = NOW()
+		RETURNING daily_max, weekly_max, daily_min, updated_at
+	`
+
+	var saved Goals
+	err := m.DB.QueryRow(ctx, query, userID, goals.DailyMax, goals.WeeklyMax, goals.DailyMin).Scan(
+		&saved.DailyMax,
+		&saved.WeeklyMax,
+		&saved.DailyMin,
+		&saved.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+// GetProgress measures the intake on the day containing at, and in the week
+// containing it, against the user's goals, with days taken in loc and weeks
+// beginning on weekStart. Pass the current time for today's progress, or an
+// entry's eaten_at to see the limits a backdated entry counts towards.
+func (m *GoalModel) GetProgress(ctx context.Context, userID int, at time.Time, loc *time.Location, weekStart time.Weekday) (*GoalProgress, error) {
+	goals, err := m.GetGoals(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	day := civilDate(at.In(loc))
+	weekBegin := bucketStart(day, BucketWeek, weekStart)
+	weekEnd := weekBegin.AddDate(0, 0, 6)
+	totals, err := dailyTotals(ctx, m.DB, userID, weekBegin, weekEnd, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &GoalProgress{
+		Goals:    *goals,
+		Date:     day.Format(dateLayout),
+		DayTotal: totals[day.Format(dateLayout)],
+		Exceeded: []string{},
+	}
+	for _, total := range totals {
+		progress.WeekTotal += total
+	}
+
+	if goals.DailyMax != nil {
+		remaining := max(*goals.DailyMax-progress.DayTotal, 0)
+		progress.DailyRemaining = &remaining
+		if progress.DayTotal > *goals.DailyMax {
+			progress.Exceeded = append(progress.Exceeded, LimitDailyMax)
+		}
+	}
+	if goals.WeeklyMax != nil {
+		remaining := max(*goals.WeeklyMax-progress.WeekTotal, 0)
+		progress.WeeklyRemaining = &remaining
+		if progress.WeekTotal > *goals.WeeklyMax {
+			progress.Exceeded = append(progress.Exceeded, LimitWeeklyMax)
+		}
+	}
+	if goals.DailyMin != nil {
+		met := progress.DayTotal >= *goals.DailyMin
+		progress.DailyMinMet = &met
+	}
+
+	return progress, nil
+}
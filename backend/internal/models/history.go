@@ -0,0 +1,180 @@
+package models
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EntryFilter narrows the entries returned by ListEntries. Zero fields match
+// everything. From is inclusive and To exclusive.
+type EntryFilter struct {
+	Meal      string
+	Tag       string
+	From      *time.Time
+	To        *time.Time
+	MinAmount *int
+	MaxAmount *int
+	EggTypeID *int
+}
+
+// EntryQuery selects a page of entries for ListEntries. Entries are ordered
+// by eaten_at then id, newest first unless Ascending is set. After continues
+// from the cursor returned with the previous page.
+type EntryQuery struct {
+	Filter    EntryFilter
+	Limit     int
+	Ascending bool
+	After     *EntryCursor
+}
+
+// EntryCursor marks the position of the last entry on a page, and the order
+// the pages run in.
+type EntryCursor struct {
+	EatenAt   time.Time
+	ID        int
+	Ascending bool
+}
+
+// String encodes the cursor as an opaque URL-safe token.
+func (c EntryCursor) String() string {
+	order := "d"
+	if c.Ascending {
+		order = "a"
+	}
+	raw := order + ":" + strconv.FormatInt(c.EatenAt.UnixNano(), 10) + ":" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseEntryCursor decodes a token produced by EntryCursor.String.
+func ParseEntryCursor(token string) (*EntryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || (parts[0] != "a" && parts[0] != "d") {
+		return nil, ErrInvalidCursor
+	}
+	order, nanos, id := parts[0], parts[1], parts[2]
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	entryID, err := strconv.Atoi(id)
+	if err != nil || entryID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &EntryCursor{EatenAt: time.Unix(0, n).UTC(), ID: entryID, Ascending: order == "a"}, nil
+}
+
+// ListEntries retrieves a page of the user's entries matching q, along with
+// the cursor of the next page or nil if this is the last one. Like
+// GetRecentEggEntries it includes undone entries but not reversal rows. It
+// returns ErrInvalidCursor if q.After was issued for the other order.
+func (m *EggModel) ListEntries(ctx context.Context, userID int, q EntryQuery) ([]EggCount, *EntryCursor, error) {
+	if q.After != nil && q.After.Ascending != q.Ascending {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	direction, comparison := "DESC", "<"
+	if q.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	var afterEatenAt *time.Time
+	var afterID *int
+	if q.After != nil {
+		afterEatenAt, afterID = &q.After.EatenAt, &q.After.ID
+	}
+
+	// Optional filters are passed as NULL when unset so the query text stays
+	// the same for every combination.
+	query := `
+		SELECT ` + eggCountColumns + `
+		FROM eggcount
+		WHERE eggcount.user_id = $1 AND eggcount.reverts_entry_id IS NULL
+		  AND ($3::text IS NULL OR EXISTS (
+			SELECT 1 FROM entry_context
+			WHERE entry_context.entry_id = eggcount.id AND entry_context.meal = $3
+		  ))
+		  AND ($4::text IS NULL OR EXISTS (
+			SELECT 1 FROM entry_tags JOIN tags ON tags.id = entry_tags.tag_id
+			WHERE entry_tags.entry_id = eggcount.id AND LOWER(tags.name) = LOWER($4)
+		  ))
+		  AND ($5::timestamptz IS NULL OR eggcount.eaten_at >= $5)
+		  AND ($6::timestamptz IS NULL OR eggcount.eaten_at < $6)
+		  AND ($7::int IS NULL OR eggcount.amount >= $7)
+		  AND ($8::int IS NULL OR eggcount.amount <= $8)
+		  AND ($9::int IS NULL OR eggcount.egg_type_id = $9)
+		  AND ($10::timestamptz IS NULL OR (eggcount.eaten_at, eggcount.id) ` + comparison + ` ($10, $11::int))
+		ORDER BY eggcount.eaten_at ` + direction + `, eggcount.id ` + direction + `
+		LIMIT $2
+	`
+
+	// One extra row tells us whether there is another page.
+	rows, err := m.DB.Query(ctx, query,
+		userID, q.Limit+1,
+		nullIfEmpty(q.Filter.Meal), nullIfEmpty(q.Filter.Tag),
+		q.Filter.From, q.Filter.To,
+		q.Filter.MinAmount, q.Filter.MaxAmount,
+		q.Filter.EggTypeID,
+		afterEatenAt, afterID,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying entries: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []EggCount{}
+	for rows.Next() {
+		var entry EggCount
+		if err := scanEggCount(rows, &entry); err != nil {
+			return nil, nil, fmt.Errorf("error scanning entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(entries) <= q.Limit {
+		return entries, nil, nil
+	}
+
+	entries = entries[:q.Limit]
+	last := entries[len(entries)-1]
+	return entries, &EntryCursor{EatenAt: last.EatenAt, ID: last.ID, Ascending: q.Ascending}, nil
+}
+
+// eachEntryBatch is how many entries EachEntry reads per query.
+const eachEntryBatch = 1000
+
+// EachEntry calls fn for every one of the user's entries, oldest first. It
+// reads them in batches of eachEntryBatch, so the whole history is never held
+// in memory and no connection is held while fn runs, however slowly. Undone
+// entries are included; reversal rows are not. It stops at the first error
+// fn returns.
+func (m *EggModel) EachEntry(ctx context.Context, userID int, fn func(*EggCount) error) error {
+	q := EntryQuery{Limit: eachEntryBatch, Ascending: true}
+	for {
+		entries, next, err := m.ListEntries(ctx, userID, q)
+		if err != nil {
+			return err
+		}
+		for i := range entries {
+			if err := fn(&entries[i]); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		q.After = next
+	}
+}
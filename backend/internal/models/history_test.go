@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEntryCursorRoundTrip(t *testing.T) {
+	for _, ascending := range []bool{false, true} {
+		c := EntryCursor{EatenAt: time.Date(2026, 3, 4, 5, 6, 7, 8000, time.UTC), ID: 42, Ascending: ascending}
+
+		got, err := ParseEntryCursor(c.String())
+		if err != nil {
+			t.Fatalf("ParseEntryCursor(%s): %v", c.String(), err)
+		}
+		if !got.EatenAt.Equal(c.EatenAt) || got.ID != c.ID || got.Ascending != c.Ascending {
+			t.Errorf("ParseEntryCursor round trip = %+v, want %+v", got, c)
+		}
+	}
+}
+
+func TestParseEntryCursorRejectsMalformed(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+
+	for _, token := range []string{
+		"not base64!",
+		encode("1700000000000000000:42"),
+		encode("x:1700000000000000000:42"),
+		encode("a:soon:42"),
+		encode("d:1700000000000000000:0"),
+	} {
+		if _, err := ParseEntryCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("ParseEntryCursor(%q) = %v, want ErrInvalidCursor", token, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// importBatchSize is how many entries each CopyFrom round trip inserts.
+const importBatchSize = 1000
+
+// ImportRow is one validated entry to insert with ImportEntries. A nil
+// EggTypeID means a large chicken egg.
+type ImportRow struct {
+	Amount    int
+	EatenAt   time.Time
+	EggTypeID *int
+	Meal      string
+	Note      string
+	Tags      []string
+}
+
+// ImportEntries inserts rows for the user in a single transaction, copying
+// them in batches so large imports stay fast. Either every row is imported
+// or none are. The caller validates the rows.
+func (m *EggModel) ImportEntries(ctx context.Context, userID int, rows []ImportRow) (int, error) {
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		var defaultTypeID int
+		if err := tx.QueryRow(ctx, `SELECT default_egg_type_id()`).Scan(&defaultTypeID); err != nil {
+			return err
+		}
+
+		for start := 0; start < len(rows); start += importBatchSize {
+			end := min(start+importBatchSize, len(rows))
+			if err := importBatch(ctx, tx, userID, defaultTypeID, rows[start:end]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// importBatch copies one batch of rows along with their context and tags.
+// Entry ids are taken from the sequence up front so the context and tag rows
+// can refer to them.
+func importBatch(ctx context.Context, tx pgx.Tx, userID, defaultTypeID int, batch []ImportRow) error {
+	query := `
+		SELECT nextval(pg_get_serial_sequence('eggcount', 'id'))
+		FROM generate_series(1, $1)
+	`
+	rows, err := tx.Query(ctx, query, len(batch))
+	if err != nil {
+		return err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return err
+	}
+
+	entries := make([][]any, len(batch))
+	var contexts [][]any
+	tagNames := map[string]bool{}
+	for i, row := range batch {
+		eggTypeID := defaultTypeID
+		if row.EggTypeID != nil {
+			eggTypeID = *row.EggTypeID
+		}
+		entries[i] = []any{ids[i], userID, row.Amount, row.EatenAt, eggTypeID}
+
+		if row.Meal != "" || row.Note != "" {
+			contexts = append(contexts, []any{ids[i], nullIfEmpty(row.Meal), nullIfEmpty(row.Note)})
+		}
+		for _, tag := range row.Tags {
+			tagNames[tag] = true
+		}
+	}
+
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"eggcount"},
+		[]string{"id", "user_id", "amount", "eaten_at", "egg_type_id"},
+		pgx.CopyFromRows(entries),
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(contexts) > 0 {
+		_, err = tx.CopyFrom(ctx,
+			pgx.Identifier{"entry_context"},
+			[]string{"entry_id", "meal", "note"},
+			pgx.CopyFromRows(contexts),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(tagNames) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(tagNames))
+	for name := range tagNames {
+		names = append(names, name)
+	}
+	tagIDs, err := ensureTags(ctx, tx, userID, names)
+	if err != nil {
+		return err
+	}
+
+	var entryTags [][]any
+	for i, row := range batch {
+		for _, tag := range row.Tags {
+			entryTags = append(entryTags, []any{ids[i], tagIDs[tag]})
+		}
+	}
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"entry_tags"},
+		[]string{"entry_id", "tag_id"},
+		pgx.CopyFromRows(entryTags),
+	)
+	return err
+}
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Nutrition is an amount of each tracked nutrient, either for a single egg or
+// summed over many.
+type Nutrition struct {
+	ProteinG      float64 `json:"protein_g"`
+	CaloriesKcal  float64 `json:"calories_kcal"`
+	FatG          float64 `json:"fat_g"`
+	CholesterolMg float64 `json:"cholesterol_mg"`
+}
+
+func (n Nutrition) add(o Nutrition) Nutrition {
+	return Nutrition{
+		ProteinG:      n.ProteinG + o.ProteinG,
+		CaloriesKcal:  n.CaloriesKcal + o.CaloriesKcal,
+		FatG:          n.FatG + o.FatG,
+		CholesterolMg: n.CholesterolMg + o.CholesterolMg,
+	}
+}
+
+func (n Nutrition) scale(factor float64) Nutrition {
+	return Nutrition{
+		ProteinG:      n.ProteinG * factor,
+		CaloriesKcal:  n.CaloriesKcal * factor,
+		FatG:          n.FatG * factor,
+		CholesterolMg: n.CholesterolMg * factor,
+	}
+}
+
+// rounded rounds every nutrient to one decimal place for display.
+func (n Nutrition) rounded() Nutrition {
+	round := func(v float64) float64 { return math.Round(v*10) / 10 }
+	return Nutrition{
+		ProteinG:      round(n.ProteinG),
+		CaloriesKcal:  round(n.CaloriesKcal),
+		FatG:          round(n.FatG),
+		CholesterolMg: round(n.CholesterolMg),
+	}
+}
+
+// NutritionBucket is the nutritional intake for one day, week or month.
+// UnknownEggs counts eggs whose type has no nutrition data; they are left out
+// of the totals.
+type NutritionBucket struct {
+	Start        string    `json:"start"`
+	End          string    `json:"end"`
+	Days         int       `json:"days"`
+	Eggs         int       `json:"eggs"`
+	UnknownEggs  int       `json:"unknown_eggs"`
+	Totals       Nutrition `json:"totals"`
+	DailyAverage Nutrition `json:"daily_average"`
+}
+
+// NutritionSummary is a bucketed summary of nutritional intake, shaped like Stats.
+type NutritionSummary struct {
+	Bucket       string            `json:"bucket"`
+	From         string            `json:"from"`
+	To           string            `json:"to"`
+	TimeZone     string            `json:"time_zone"`
+	Eggs         int               `json:"eggs"`
+	UnknownEggs  int               `json:"unknown_eggs"`
+	Totals       Nutrition         `json:"totals"`
+	DailyAverage Nutrition         `json:"daily_average"`
+	Buckets      []NutritionBucket `json:"buckets"`
+}
+
+// NutritionModel handles database operations for the egg_nutrition table.
+type NutritionModel struct {
+	DB *pgxpool.Pool
+}
+
+// NewNutritionModel creates a new instance of NutritionModel.
+func NewNutritionModel(db *pgxpool.Pool) *NutritionModel {
+	return &NutritionModel{DB: db}
+}
+
+// setNutrition stores the per-egg nutrition for an egg type inside tx.
+func setNutrition(ctx context.Context, tx pgx.Tx, eggTypeID int, n Nutrition) error {
+	query := `
+		INSERT INTO egg_nutrition (egg_type_id, protein_g, calories_kcal, fat_g, cholesterol_mg)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (egg_type_id) DO UPDATE
+		SET protein_g = EXCLUDED.protein_g,
+			calories_kcal = EXCLUDED.calories_kcal,
+			fat_g = EXCLUDED.fat_g,
+			cholesterol_mg = EXCLUDED.cholesterol_mg
+	`
+	_, err := tx.Exec(ctx, query, eggTypeID, n.ProteinG, n.CaloriesKcal, n.FatG, n.CholesterolMg)
+	return err
+}
+
+// Annotate fills in Nutrition on each entry from its egg type and amount.
+// Entries whose type has no nutrition data are left with a nil Nutrition.
+func (m *NutritionModel) Annotate(ctx context.Context, entries []EggCount) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	typeIDs := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		typeIDs = append(typeIDs, entry.EggTypeID)
+	}
+
+	query := `
+		SELECT egg_type_id, protein_g, calories_kcal, fat_g, cholesterol_mg
+		FROM egg_nutrition
+		WHERE egg_type_id = ANY($1)
+	`
+	rows, err := m.DB.Query(ctx, query, typeIDs)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	perEgg := map[int]Nutrition{}
+	for rows.Next() {
+		var eggTypeID int
+		var n Nutrition
+		if err := rows.Scan(&eggTypeID, &n.ProteinG, &n.CaloriesKcal, &n.FatG, &n.CholesterolMg); err != nil {
+			return err
+		}
+		perEgg[eggTypeID] = n
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if n, ok := perEgg[entries[i].EggTypeID]; ok {
+			total := n.scale(float64(entries[i].Amount)).rounded()
+			entries[i].Nutrition = &total
+		}
+	}
+
+	return nil
+}
+
+// GetSummary computes the user's nutritional intake per bucket over the range.
+func (m *NutritionModel) GetSummary(ctx context.Context, userID int, r StatsRange) (*NutritionSummary, error) {
+	daily, err := m.dailyNutrition(ctx, userID, r.From, r.To, r.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &NutritionSummary{
+		Bucket:   r.Bucket,
+		From:     r.From.Format(dateLayout),
+		To:       r.To.Format(dateLayout),
+		TimeZone: r.Location.String(),
+		Buckets:  []NutritionBucket{},
+	}
+
+	var days int
+	for _, bucket := range bucketDays(r) {
+		b := NutritionBucket{
+			Start: bucket[0].Format(dateLayout),
+			End:   bucket[len(bucket)-1].Format(dateLayout),
+			Days:  len(bucket),
+		}
+		for _, day := range bucket {
+			d := daily[day.Format(dateLayout)]
+			b.Eggs += d.eggs
+			b.UnknownEggs += d.unknownEggs
+			b.Totals = b.Totals.add(d.totals)
+		}
+		days += b.Days
+
+		summary.Eggs += b.Eggs
+		summary.UnknownEggs += b.UnknownEggs
+		summary.Totals = summary.Totals.add(b.Totals)
+
+		b.DailyAverage = b.Totals.scale(1 / float64(b.Days)).rounded()
+		b.Totals = b.Totals.rounded()
+		summary.Buckets = append(summary.Buckets, b)
+	}
+	if days > 0 {
+		summary.DailyAverage = summary.Totals.scale(1 / float64(days)).rounded()
+	}
+	summary.Totals = summary.Totals.rounded()
+
+	return summary, nil
+}
+
+type dayNutrition struct {
+	eggs        int
+	unknownEggs int
+	totals      Nutrition
+}
+
+// dailyNutrition sums nutrients per local calendar day between from and to
+// inclusive, keyed by date.
+func (m *NutritionModel) dailyNutrition(ctx context.Context, userID int, from, to time.Time, loc *time.Location) (map[string]dayNutrition, error) {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	end := time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, loc)
+
+	query := `
+		SELECT
+			(eggcount.eaten_at AT TIME ZONE $2)::date AS day,
+			SUM(eggcount.amount),
+			COALESCE(SUM(eggcount.amount) FILTER (WHERE egg_nutrition.egg_type_id IS NULL), 0),
+			COALESCE(SUM(eggcount.amount * egg_nutrition.protein_g), 0),
+			COALESCE(SUM(eggcount.amount * egg_nutrition.calories_kcal), 0),
+			COALESCE(SUM(eggcount.amount * egg_nutrition.fat_g), 0),
+			COALESCE(SUM(eggcount.amount * egg_nutrition.cholesterol_mg), 0)
+		FROM eggcount
+		LEFT JOIN egg_nutrition ON egg_nutrition.egg_type_id = eggcount.egg_type_id
+		WHERE eggcount.user_id = $1
+		  AND eggcount.eaten_at >= $3 AND eggcount.eaten_at < $4
+		  AND ` + countedEntries + `
+		GROUP BY day
+	`
+	rows, err := m.DB.Query(ctx, query, userID, loc.String(), start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	daily := map[string]dayNutrition{}
+	for rows.Next() {
+		var day time.Time
+		var d dayNutrition
+		err := rows.Scan(
+			&day,
+			&d.eggs,
+			&d.unknownEggs,
+			&d.totals.ProteinG,
+			&d.totals.CaloriesKcal,
+			&d.totals.FatG,
+			&d.totals.CholesterolMg,
+		)
+		if err != nil {
+			return nil, err
+		}
+		daily[day.Format(dateLayout)] = d
+	}
+
+	return daily, rows.Err()
+}
@@ -0,0 +1,392 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Recipe is a dish with a known number of eggs of each type per serving.
+type Recipe struct {
+	ID             int          `json:"id"`
+	Name           string       `json:"name"`
+	Description    *string      `json:"description"`
+	Eggs           []RecipeEggs `json:"eggs"`
+	EggsPerServing int          `json:"eggs_per_serving"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      *time.Time   `json:"updated_at"`
+}
+
+// RecipeEggs is the number of eggs of one type in a serving.
+type RecipeEggs struct {
+	EggTypeID int    `json:"egg_type_id"`
+	EggType   string `json:"egg_type"`
+	Amount    int    `json:"amount"`
+}
+
+// RecipeInput describes a recipe to create or replace. Only EggTypeID and
+// Amount of each RecipeEggs are used.
+type RecipeInput struct {
+	Name        string
+	Description string
+	Eggs        []RecipeEggs
+}
+
+// RecipeServing describes one logging of a recipe with LogRecipe. A zero
+// EatenAt means now; Meal, Note and Tags are copied onto every entry.
+type RecipeServing struct {
+	Servings int
+	EatenAt  time.Time
+	Meal     string
+	Note     string
+	Tags     []string
+}
+
+// RecipeLog records a recipe being logged and the entries it expanded into.
+type RecipeLog struct {
+	ID        int        `json:"id"`
+	RecipeID  int        `json:"recipe_id"`
+	Servings  int        `json:"servings"`
+	EatenAt   time.Time  `json:"eaten_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	Entries   []EggCount `json:"entries"`
+}
+
+// RecipeUsage summarises how often a recipe has been logged. Logs whose
+// entries have all been undone are not counted.
+type RecipeUsage struct {
+	RecipeID     int        `json:"recipe_id"`
+	Name         string     `json:"name"`
+	TimesLogged  int        `json:"times_logged"`
+	Servings     int        `json:"servings"`
+	Eggs         int        `json:"eggs"`
+	LastLoggedAt *time.Time `json:"last_logged_at"`
+}
+
+// RecipeModel handles database operations for the recipes tables.
+type RecipeModel struct {
+	DB *pgxpool.Pool
+}
+
+// NewRecipeModel creates a new instance of RecipeModel.
+func NewRecipeModel(db *pgxpool.Pool) *RecipeModel {
+	return &RecipeModel{DB: db}
+}
+
+// ListRecipes retrieves the user's recipes ordered by name.
+func (m *RecipeModel) ListRecipes(ctx context.Context, userID int) ([]Recipe, error) {
+	return m.recipes(ctx, userID, nil)
+}
+
+// GetRecipe retrieves one of the user's recipes. It returns ErrNoRecord if
+// the recipe doesn't exist or belongs to another user.
+func (m *RecipeModel) GetRecipe(ctx context.Context, userID, recipeID int) (*Recipe, error) {
+	recipes, err := m.recipes(ctx, userID, &recipeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipes) == 0 {
+		return nil, ErrNoRecord
+	}
+	return &recipes[0], nil
+}
+
+// CreateRecipe adds a recipe for the user. It returns ErrDuplicateRecipe if
+// they already have one with the same name and ErrUnknownEggType for an egg
+// type they cannot use.
+func (m *RecipeModel) CreateRecipe(ctx context.Context, userID int, in RecipeInput) (*Recipe, error) {
+	if err := m.checkEggTypes(ctx, userID, in.Eggs); err != nil {
+		return nil, err
+	}
+
+	var recipeID int
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO recipes (user_id, name, description)
+			VALUES ($1, $2, NULLIF($3, ''))
+			RETURNING id
+		`
+		if err := tx.QueryRow(ctx, query, userID, in.Name, in.Description).Scan(&recipeID); err != nil {
+			return err
+		}
+		return insertRecipeEggs(ctx, tx, recipeID, in.Eggs)
+	})
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return nil, ErrDuplicateRecipe
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return m.GetRecipe(ctx, userID, recipeID)
+}
+
+// UpdateRecipe replaces one of the user's recipes. Entries already logged
+// from it keep their amounts. It returns ErrNoRecord if the recipe isn't
+// theirs, plus the errors of CreateRecipe.
+func (m *RecipeModel) UpdateRecipe(ctx context.Context, userID, recipeID int, in RecipeInput) (*Recipe, error) {
+	if err := m.checkEggTypes(ctx, userID, in.Eggs); err != nil {
+		return nil, err
+	}
+
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		query := `
+			UPDATE recipes
+			SET name = $3, description = NULLIF($4, ''), updated_at = NOW()
+			WHERE id = $1 AND user_id = $2
+		`
+		tag, err := tx.Exec(ctx, query, recipeID, userID, in.Name, in.Description)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNoRecord
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM recipe_eggs WHERE recipe_id = $1`, recipeID); err != nil {
+			return err
+		}
+		return insertRecipeEggs(ctx, tx, recipeID, in.Eggs)
+	})
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return nil, ErrDuplicateRecipe
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return m.GetRecipe(ctx, userID, recipeID)
+}
+
+// DeleteRecipe removes one of the user's recipes. Entries logged from it are
+// kept but no longer link back to it. It returns ErrNoRecord if the recipe
+// isn't theirs.
+func (m *RecipeModel) DeleteRecipe(ctx context.Context, userID, recipeID int) error {
+	query := `
+		DELETE FROM recipes
+		WHERE id = $1 AND user_id = $2
+	`
+	tag, err := m.DB.Exec(ctx, query, recipeID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// LogRecipe records servings of one of the user's recipes as one entry per
+// egg type, each linked back to the recipe. It returns ErrNoRecord if the
+// recipe isn't theirs and ErrEatenAtInFuture for an eaten_at in the future.
+func (m *RecipeModel) LogRecipe(ctx context.Context, userID, recipeID int, s RecipeServing) (*RecipeLog, error) {
+	if s.EatenAt.IsZero() {
+		s.EatenAt = time.Now()
+	}
+	if s.EatenAt.After(time.Now().Add(MaxClockSkew)) {
+		return nil, ErrEatenAtInFuture
+	}
+
+	var recipeLog RecipeLog
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		// Lock the recipe so an edit can't change its eggs halfway through.
+		query := `SELECT id FROM recipes WHERE id = $1 AND user_id = $2 FOR SHARE`
+		err := tx.QueryRow(ctx, query, recipeID, userID).Scan(&recipeID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoRecord
+		}
+		if err != nil {
+			return err
+		}
+
+		query = `
+			SELECT egg_type_id, amount
+			FROM recipe_eggs
+			WHERE recipe_id = $1
+			ORDER BY egg_type_id
+		`
+		rows, err := tx.Query(ctx, query, recipeID)
+		if err != nil {
+			return err
+		}
+		eggs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (RecipeEggs, error) {
+			var e RecipeEggs
+			err := row.Scan(&e.EggTypeID, &e.Amount)
+			return e, err
+		})
+		if err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO recipe_logs (user_id, recipe_id, servings, eaten_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, recipe_id, servings, eaten_at, created_at
+		`
+		err = tx.QueryRow(ctx, query, userID, recipeID, s.Servings, s.EatenAt).Scan(
+			&recipeLog.ID,
+			&recipeLog.RecipeID,
+			&recipeLog.Servings,
+			&recipeLog.EatenAt,
+			&recipeLog.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		recipeLog.Entries = []EggCount{}
+		for _, e := range eggs {
+			entry, err := insertEntry(ctx, tx, userID, NewEntry{
+				Amount:    e.Amount * s.Servings,
+				EatenAt:   s.EatenAt,
+				EggTypeID: &e.EggTypeID,
+				Meal:      s.Meal,
+				Note:      s.Note,
+				Tags:      s.Tags,
+			}, &recipeLog.ID)
+			if err != nil {
+				return err
+			}
+			recipeLog.Entries = append(recipeLog.Entries, *entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &recipeLog, nil
+}
+
+// GetRecipeUsage retrieves usage statistics for each of the user's recipes,
+// most used first.
+func (m *RecipeModel) GetRecipeUsage(ctx context.Context, userID int) ([]RecipeUsage, error) {
+	query := `
+		WITH counted_logs AS (
+			SELECT recipe_logs.id, recipe_logs.recipe_id, recipe_logs.servings,
+				recipe_logs.eaten_at, SUM(eggcount.amount) AS eggs
+			FROM recipe_logs
+			JOIN eggcount ON eggcount.recipe_log_id = recipe_logs.id AND ` + countedEntries + `
+			WHERE recipe_logs.user_id = $1
+			GROUP BY recipe_logs.id
+		)
+		SELECT recipes.id, recipes.name, COUNT(counted_logs.id),
+			COALESCE(SUM(counted_logs.servings), 0)::bigint,
+			COALESCE(SUM(counted_logs.eggs), 0)::bigint,
+			MAX(counted_logs.eaten_at)
+		FROM recipes
+		LEFT JOIN counted_logs ON counted_logs.recipe_id = recipes.id
+		WHERE recipes.user_id = $1
+		GROUP BY recipes.id
+		ORDER BY COUNT(counted_logs.id) DESC, LOWER(recipes.name)
+	`
+	rows, err := m.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying recipe usage: %v", err)
+	}
+	defer rows.Close()
+
+	usage := []RecipeUsage{}
+	for rows.Next() {
+		var u RecipeUsage
+		err := rows.Scan(&u.RecipeID, &u.Name, &u.TimesLogged, &u.Servings, &u.Eggs, &u.LastLoggedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning recipe usage: %v", err)
+		}
+		usage = append(usage, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// recipes loads the user's recipes, or just one when recipeID is set.
+func (m *RecipeModel) recipes(ctx context.Context, userID int, recipeID *int) ([]Recipe, error) {
+	query := `
+		SELECT id, name, description, created_at, updated_at
+		FROM recipes
+		WHERE user_id = $1 AND ($2::int IS NULL OR id = $2)
+		ORDER BY LOWER(name), id
+	`
+	rows, err := m.DB.Query(ctx, query, userID, recipeID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying recipes: %v", err)
+	}
+	defer rows.Close()
+
+	recipes := []Recipe{}
+	index := map[int]int{}
+	for rows.Next() {
+		r := Recipe{Eggs: []RecipeEggs{}}
+		if err := rows.Scan(&r.ID, &r.Name, &r.Description, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning recipe: %v", err)
+		}
+		index[r.ID] = len(recipes)
+		recipes = append(recipes, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT recipe_eggs.recipe_id, recipe_eggs.egg_type_id, egg_types.name, recipe_eggs.amount
+		FROM recipe_eggs
+		JOIN recipes ON recipes.id = recipe_eggs.recipe_id
+		JOIN egg_types ON egg_types.id = recipe_eggs.egg_type_id
+		WHERE recipes.user_id = $1 AND ($2::int IS NULL OR recipes.id = $2)
+		ORDER BY recipe_eggs.amount DESC, egg_types.id
+	`
+	rows, err = m.DB.Query(ctx, query, userID, recipeID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying recipe eggs: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var e RecipeEggs
+		if err := rows.Scan(&id, &e.EggTypeID, &e.EggType, &e.Amount); err != nil {
+			return nil, fmt.Errorf("error scanning recipe eggs: %v", err)
+		}
+		if i, ok := index[id]; ok {
+			recipes[i].Eggs = append(recipes[i].Eggs, e)
+			recipes[i].EggsPerServing += e.Amount
+		}
+	}
+
+	return recipes, rows.Err()
+}
+
+func (m *RecipeModel) checkEggTypes(ctx context.Context, userID int, eggs []RecipeEggs) error {
+	for _, e := range eggs {
+		if err := checkEggType(ctx, m.DB, userID, e.EggTypeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertRecipeEggs(ctx context.Context, tx pgx.Tx, recipeID int, eggs []RecipeEggs) error {
+	query := `
+		INSERT INTO recipe_eggs (recipe_id, egg_type_id, amount)
+		VALUES ($1, $2, $3)
+	`
+	for _, e := range eggs {
+		if _, err := tx.Exec(ctx, query, recipeID, e.EggTypeID, e.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
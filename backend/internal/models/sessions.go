@@ -0,0 +1,155 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SessionInfo describes one of a user's live sessions.
+type SessionInfo struct {
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Current   bool      `json:"current"`
+}
+
+// SessionModel handles database operations for the user_sessions table,
+// which ties the scs sessions table to users.
+type SessionModel struct {
+	DB *pgxpool.Pool
+}
+
+// NewSessionModel creates a new instance of SessionModel.
+func NewSessionModel(db *pgxpool.Pool) *SessionModel {
+	return &SessionModel{DB: db}
+}
+
+// RecordSession notes that the session with token belongs to the user.
+func (m *SessionModel) RecordSession(ctx context.Context, token string, userID int, ipAddress, userAgent string) error {
+	query := `
+		INSERT INTO user_sessions (token, user_id, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (token) DO UPDATE
+		SET user_id = EXCLUDED.user_id,
+			ip_address = EXCLUDED.ip_address,
+			user_agent = EXCLUDED.user_agent,
+			created_at = NOW()
+	`
+	_, err := m.DB.Exec(ctx, query, token, userID, ipAddress, userAgent)
+	return err
+}
+
+// ListSessions retrieves the user's unexpired sessions, newest first.
+// currentToken marks the session making the request.
+func (m *SessionModel) ListSessions(ctx context.Context, userID int, currentToken string) ([]SessionInfo, error) {
+	query := `
+		SELECT user_sessions.ip_address, user_sessions.user_agent, user_sessions.created_at,
+			sessions.expiry, user_sessions.token = $2
+		FROM user_sessions
+		JOIN sessions ON sessions.token = user_sessions.token
+		WHERE user_sessions.user_id = $1 AND sessions.expiry > NOW()
+		ORDER BY user_sessions.created_at DESC
+	`
+	rows, err := m.DB.Query(ctx, query, userID, currentToken)
+	if err != nil {
+		return nil, fmt.Errorf("error querying sessions: %v", err)
+	}
+	defer rows.Close()
+
+	sessions := []SessionInfo{}
+	for rows.Next() {
+		var s SessionInfo
+		if err := rows.Scan(&s.IPAddress, &s.UserAgent, &s.CreatedAt, &s.ExpiresAt, &s.Current); err != nil {
+			return nil, fmt.Errorf("error scanning session: %v", err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// GetSessionEpoch retrieves the user's session epoch, which changes every
+// time their sessions are revoked.
+func (m *SessionModel) GetSessionEpoch(ctx context.Context, userID int) (int, error) {
+	query := `SELECT session_epoch FROM users WHERE id = $1`
+
+	var epoch int
+	err := m.DB.QueryRow(ctx, query, userID).Scan(&epoch)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNoRecord
+	}
+	if err != nil {
+		return 0, err
+	}
+	return epoch, nil
+}
+
+// DeleteUserSessions ends all of the user's sessions except the one with
+// keepToken, which may be empty to end them all. It returns how many were
+// ended. The session epoch moves on too, so sessions user_sessions doesn't
+// know about stop working; a kept session must store the new epoch.
+func (m *SessionModel) DeleteUserSessions(ctx context.Context, userID int, keepToken string) (int64, error) {
+	var ended int64
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		var err error
+		ended, err = deleteUserSessions(ctx, tx, userID, keepToken)
+		return err
+	})
+	return ended, err
+}
+
+// deleteUserSessions is DeleteUserSessions inside tx, for changes that log
+// the user out as part of a larger transaction.
+func deleteUserSessions(ctx context.Context, tx pgx.Tx, userID int, keepToken string) (int64, error) {
+	query := `
+		DELETE FROM sessions
+		WHERE token IN (
+			SELECT token FROM user_sessions
+			WHERE user_id = $1 AND token <> $2
+		)
+	`
+	tag, err := tx.Exec(ctx, query, userID, keepToken)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `DELETE FROM user_sessions WHERE user_id = $1 AND token <> $2`
+	if _, err := tx.Exec(ctx, query, userID, keepToken); err != nil {
+		return 0, err
+	}
+
+	query = `UPDATE users SET session_epoch = session_epoch + 1 WHERE id = $1`
+	if _, err := tx.Exec(ctx, query, userID); err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteStaleSessions removes user_sessions rows whose session has expired
+// or been destroyed. Rows younger than a minute are kept, since scs only
+// saves a new session after the login request finishes.
+func (m *SessionModel) DeleteStaleSessions(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM user_sessions
+		WHERE created_at < NOW() - INTERVAL '1 minute'
+		  AND NOT EXISTS (
+			SELECT 1 FROM sessions
+			WHERE sessions.token = user_sessions.token AND sessions.expiry > NOW()
+		  )
+	`
+	tag, err := m.DB.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
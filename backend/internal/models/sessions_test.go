@@ -0,0 +1,37 @@
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDeleteUserSessionsAdvancesEpoch(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	m := NewSessionModel(db)
+
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+
+	before, err := m.GetSessionEpoch(ctx, alice)
+	if err != nil {
+		t.Fatalf("GetSessionEpoch: %v", err)
+	}
+	if _, err := m.DeleteUserSessions(ctx, alice, ""); err != nil {
+		t.Fatalf("DeleteUserSessions: %v", err)
+	}
+
+	// Untracked sessions are stamped with the old epoch, so they no longer match.
+	after, err := m.GetSessionEpoch(ctx, alice)
+	if err != nil {
+		t.Fatalf("GetSessionEpoch: %v", err)
+	}
+	if after == before {
+		t.Fatalf("epoch stayed at %d after revoking sessions", after)
+	}
+
+	// Other users are unaffected.
+	if epoch, err := m.GetSessionEpoch(ctx, bob); err != nil || epoch != 0 {
+		t.Fatalf("GetSessionEpoch(bob) = %d, %v; want 0", epoch, err)
+	}
+}
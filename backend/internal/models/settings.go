@@ -0,0 +1,144 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/text/language"
+)
+
+// Settings holds a user's regional preferences. TimeZone is an IANA name and
+// decides where the user's days begin and end; WeekStart uses time.Weekday
+// numbering (0 is Sunday); Locale is a BCP 47 language tag, whose region
+// decides how FormatTime writes dates and times.
+type Settings struct {
+	TimeZone  string     `json:"time_zone"`
+	WeekStart int        `json:"week_start"`
+	Locale    string     `json:"locale"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// DefaultSettings returns the settings used for users who haven't saved any.
+func DefaultSettings() Settings {
+	return Settings{
+		TimeZone:  "UTC",
+		WeekStart: int(time.Monday),
+		Locale:    "en",
+	}
+}
+
+// Location loads the settings' time zone.
+func (s *Settings) Location() (*time.Location, error) {
+	return time.LoadLocation(s.TimeZone)
+}
+
+// Regions whose locales write dates month first or use a 12-hour clock.
+// Everywhere else gets day first and a 24-hour clock.
+var (
+	monthFirstRegions = []string{"US", "CA", "PH"}
+	twelveHourRegions = []string{"US", "CA", "PH", "AU", "NZ", "IN"}
+)
+
+// FormatTime formats t for messages to the user, in their time zone and with
+// the date order and clock their locale's region expects. Month names are
+// always English.
+func (s *Settings) FormatTime(t time.Time) string {
+	if loc, err := s.Location(); err == nil {
+		t = t.In(loc)
+	} else {
+		t = t.UTC()
+	}
+
+	// Only an explicit region counts, so a bare "en" keeps the default.
+	var region string
+	if tag, err := language.Parse(s.Locale); err == nil {
+		if r, conf := tag.Region(); conf == language.Exact {
+			region = r.String()
+		}
+	}
+
+	clock := "15:04 MST"
+	if slices.Contains(twelveHourRegions, region) {
+		clock = "3:04 PM MST"
+	}
+	date := "2 January 2006"
+	if slices.Contains(monthFirstRegions, region) {
+		date = "January 2, 2006"
+	}
+	return t.Format(clock + " on " + date)
+}
+
+// SettingsModel handles database operations for the user_settings table.
+type SettingsModel struct {
+	DB *pgxpool.Pool
+}
+
+// NewSettingsModel creates a new instance of SettingsModel.
+func NewSettingsModel(db *pgxpool.Pool) *SettingsModel {
+	return &SettingsModel{DB: db}
+}
+
+// GetSettings retrieves the user's settings, or the defaults if none are saved.
+func (m *SettingsModel) GetSettings(ctx context.Context, userID int) (*Settings, error) {
+	settings := DefaultSettings()
+
+	query := `
+		SELECT time_zone, week_start, locale, updated_at
+		FROM user_settings
+		WHERE user_id = $1
+	`
+	err := m.DB.QueryRow(ctx, query, userID).Scan(
+		&settings.TimeZone,
+		&settings.WeekStart,
+		&settings.Locale,
+		&settings.UpdatedAt,
+	)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+// SaveSettings creates or replaces the user's settings. Callers validate the
+// values first.
+func (m *SettingsModel) SaveSettings(ctx context.Context, userID int, settings Settings) (*Settings, error) {
+	query := `
+		INSERT INTO user_settings (user_id, time_zone, week_start, locale)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET time_zone = EXCLUDED.time_zone,
+			week_start = EXCLUDED.week_start,
+			locale = EXCLUDED.locale,
+			updated_at = NOW()
+		RETURNING time_zone, week_start, locale, updated_at
+	`
+
+	var saved Settings
+	err := m.DB.QueryRow(ctx, query, userID, settings.TimeZone, settings.WeekStart, settings.Locale).Scan(
+		&saved.TimeZone,
+		&saved.WeekStart,
+		&saved.Locale,
+		&saved.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+// IsKnownTimeZone reports whether Postgres recognises name as a time zone.
+// Queries use it with AT TIME ZONE, and the server's zone data can differ
+// from the copy embedded in the binary.
+func (m *SettingsModel) IsKnownTimeZone(ctx context.Context, name string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM pg_timezone_names WHERE name = $1)`
+
+	var known bool
+	err := m.DB.QueryRow(ctx, query, name).Scan(&known)
+	return known, err
+}
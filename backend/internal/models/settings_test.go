@@ -0,0 +1,27 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSettingsFormatTime(t *testing.T) {
+	at := time.Date(2026, time.March, 4, 21, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		timeZone, locale string
+		want             string
+	}{
+		{"UTC", "en", "21:30 UTC on 4 March 2026"},
+		{"Australia/Sydney", "en-AU", "8:30 AM AEDT on 5 March 2026"},
+		{"America/New_York", "en-US", "4:30 PM EST on March 4, 2026"},
+		{"Europe/Berlin", "de-DE", "22:30 CET on 4 March 2026"},
+		{"Not/AZone", "en-GB", "21:30 UTC on 4 March 2026"},
+	}
+	for _, tt := range tests {
+		s := Settings{TimeZone: tt.timeZone, Locale: tt.locale}
+		if got := s.FormatTime(at); got != tt.want {
+			t.Errorf("FormatTime in %s/%s = %q, want %q", tt.timeZone, tt.locale, got, tt.want)
+		}
+	}
+}
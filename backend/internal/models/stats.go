@@ -0,0 +1,178 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Bucket sizes accepted by GetStats.
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// dateLayout is the format used for calendar dates in stats responses.
+const dateLayout = "2006-01-02"
+
+// StatsRange selects the period and bucketing for GetStats. From and To are
+// calendar dates (only year, month and day are used) and are both inclusive.
+// Day boundaries are taken in Location, and weekly buckets begin on WeekStart.
+type StatsRange struct {
+	Bucket    string
+	From      time.Time
+	To        time.Time
+	Location  *time.Location
+	WeekStart time.Weekday
+}
+
+// DayTotal is the number of eggs eaten on a single calendar day.
+type DayTotal struct {
+	Date  string `json:"date"`
+	Total int    `json:"total"`
+}
+
+// StatsBucket summarises one day, week or month. Start and End are clipped to
+// the requested range, and Days is the number of days between them.
+type StatsBucket struct {
+	Start         string   `json:"start"`
+	End           string   `json:"end"`
+	Days          int      `json:"days"`
+	Total         int      `json:"total"`
+	AveragePerDay float64  `json:"average_per_day"`
+	MaxDay        DayTotal `json:"max_day"`
+}
+
+// Stats is a bucketed summary of a user's consumption. Buckets with no
+// entries are included with zero totals so charts have no gaps.
+type Stats struct {
+	Bucket        string        `json:"bucket"`
+	From          string        `json:"from"`
+	To            string        `json:"to"`
+	TimeZone      string        `json:"time_zone"`
+	Total         int           `json:"total"`
+	AveragePerDay float64       `json:"average_per_day"`
+	MaxDay        DayTotal      `json:"max_day"`
+	Buckets       []StatsBucket `json:"buckets"`
+}
+
+// GetStats computes per-bucket totals for the user over the requested range.
+func (m *EggModel) GetStats(ctx context.Context, userID int, r StatsRange) (*Stats, error) {
+	daily, err := dailyTotals(ctx, m.DB, userID, r.From, r.To, r.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		Bucket:   r.Bucket,
+		From:     r.From.Format(dateLayout),
+		To:       r.To.Format(dateLayout),
+		TimeZone: r.Location.String(),
+		Buckets:  []StatsBucket{},
+	}
+
+	var days int
+	for _, bucket := range bucketDays(r) {
+		first := bucket[0].Format(dateLayout)
+		b := StatsBucket{
+			Start:  first,
+			End:    bucket[len(bucket)-1].Format(dateLayout),
+			Days:   len(bucket),
+			MaxDay: DayTotal{Date: first},
+		}
+
+		for _, day := range bucket {
+			date := day.Format(dateLayout)
+			total := daily[date]
+
+			b.Total += total
+			if total > b.MaxDay.Total {
+				b.MaxDay = DayTotal{Date: date, Total: total}
+			}
+
+			days++
+			stats.Total += total
+			if days == 1 || total > stats.MaxDay.Total {
+				stats.MaxDay = DayTotal{Date: date, Total: total}
+			}
+		}
+
+		b.AveragePerDay = float64(b.Total) / float64(b.Days)
+		stats.Buckets = append(stats.Buckets, b)
+	}
+	if days > 0 {
+		stats.AveragePerDay = float64(stats.Total) / float64(days)
+	}
+
+	return stats, nil
+}
+
+// dailyTotals sums counted entries per local calendar day between from and
+// to inclusive, keyed by date. Days without entries are absent from the map.
+func dailyTotals(ctx context.Context, db *pgxpool.Pool, userID int, from, to time.Time, loc *time.Location) (map[string]int, error) {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	end := time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, loc)
+
+	query := `
+		SELECT (eaten_at AT TIME ZONE $2)::date AS day, SUM(amount)
+		FROM eggcount
+		WHERE user_id = $1
+		  AND eaten_at >= $3 AND eaten_at < $4
+		  AND ` + countedEntries + `
+		GROUP BY day
+	`
+	rows, err := db.Query(ctx, query, userID, loc.String(), start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := map[string]int{}
+	for rows.Next() {
+		var day time.Time
+		var total int
+		if err := rows.Scan(&day, &total); err != nil {
+			return nil, err
+		}
+		totals[day.Format(dateLayout)] = total
+	}
+
+	return totals, rows.Err()
+}
+
+// bucketDays splits the range into buckets of consecutive calendar dates.
+// The first bucket may start part-way through a week or month, and the last
+// may end part-way through one.
+func bucketDays(r StatsRange) [][]time.Time {
+	var buckets [][]time.Time
+	var currentKey time.Time
+	for day := civilDate(r.From); !day.After(civilDate(r.To)); day = day.AddDate(0, 0, 1) {
+		if key := bucketStart(day, r.Bucket, r.WeekStart); len(buckets) == 0 || !key.Equal(currentKey) {
+			buckets = append(buckets, nil)
+			currentKey = key
+		}
+		buckets[len(buckets)-1] = append(buckets[len(buckets)-1], day)
+	}
+	return buckets
+}
+
+// civilDate strips t down to its calendar date at midnight UTC, which makes
+// day-by-day iteration immune to DST transitions.
+func civilDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// bucketStart returns the first day of the bucket that contains day.
+func bucketStart(day time.Time, bucket string, weekStart time.Weekday) time.Time {
+	switch bucket {
+	case BucketWeek:
+		offset := (int(day.Weekday()) - int(weekStart) + 7) % 7
+		return day.AddDate(0, 0, -offset)
+	case BucketMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
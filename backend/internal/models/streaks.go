@@ -0,0 +1,312 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Streak kinds.
+const (
+	// StreakMinimum counts consecutive days with at least Threshold eggs.
+	StreakMinimum = "minimum"
+	// StreakUnderLimit counts consecutive days with no more than Threshold eggs.
+	StreakUnderLimit = "under_limit"
+)
+
+// Thresholds used when the user hasn't chosen their own.
+const (
+	DefaultStreakMinimum = 1
+	DefaultDailyLimit    = 2
+)
+
+// Streak describes a user's current and longest run of qualifying days.
+//
+// Today counts towards the current streak as soon as it qualifies. For a
+// minimum streak, a run that ended yesterday is still current until today is
+// over; for an under-limit streak, going over the limit today ends it.
+type Streak struct {
+	Kind         string  `json:"kind"`
+	Threshold    int     `json:"threshold"`
+	Current      int     `json:"current"`
+	CurrentStart *string `json:"current_start"`
+	Longest      int     `json:"longest"`
+	LongestStart *string `json:"longest_start"`
+	LongestEnd   *string `json:"longest_end"`
+}
+
+// Streaks groups both streak kinds.
+type Streaks struct {
+	Minimum    Streak `json:"minimum"`
+	UnderLimit Streak `json:"under_limit"`
+}
+
+// StreakModel computes streaks, caching progress in the user_streaks table so
+// each request only scans the days since the last one. Each threshold has its
+// own cached row, so only the thresholds from users' goals are cached.
+type StreakModel struct {
+	DB *pgxpool.Pool
+}
+
+// NewStreakModel creates a new instance of StreakModel.
+func NewStreakModel(db *pgxpool.Pool) *StreakModel {
+	return &StreakModel{DB: db}
+}
+
+// streakState is a row of user_streaks: the streak as of the end of
+// computedThrough.
+type streakState struct {
+	threshold       int
+	timeZone        string
+	computedThrough time.Time
+	runLength       int
+	runStart        *time.Time
+	longestLength   int
+	longestStart    *time.Time
+	longestEnd      *time.Time
+	staleFrom       *time.Time
+}
+
+// GetStreaks computes both streak kinds for the user with days taken in loc,
+// using the cache as GetStreak does.
+func (m *StreakModel) GetStreaks(ctx context.Context, userID, minimum, limit int, loc *time.Location, cache bool) (*Streaks, error) {
+	minStreak, err := m.GetStreak(ctx, userID, StreakMinimum, minimum, loc, cache)
+	if err != nil {
+		return nil, err
+	}
+	limitStreak, err := m.GetStreak(ctx, userID, StreakUnderLimit, limit, loc, cache)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Streaks{Minimum: *minStreak, UnderLimit: *limitStreak}, nil
+}
+
+// GetStreak computes one kind of streak for the user with days taken in loc.
+// Without cache the streak is computed from the first entry and nothing is
+// stored, which suits one-off thresholds.
+func (m *StreakModel) GetStreak(ctx context.Context, userID int, kind string, threshold int, loc *time.Location, cache bool) (*Streak, error) {
+	today := civilDate(time.Now().In(loc))
+	yesterday := today.AddDate(0, 0, -1)
+
+	var cached *streakState
+	if cache {
+		var err error
+		cached, err = m.loadState(ctx, userID, kind, threshold)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	state := cached
+	var from time.Time
+	if state != nil && state.usable(threshold, loc) && !state.computedThrough.After(yesterday) {
+		from = state.computedThrough.AddDate(0, 0, 1)
+	} else {
+		state = nil
+	}
+	if state == nil {
+		// Start over from the user's first entry.
+		state = &streakState{threshold: threshold, timeZone: loc.String()}
+		first, err := firstEntryDate(ctx, m.DB, userID, loc)
+		if err != nil {
+			return nil, err
+		}
+		from = today
+		if first != nil {
+			from = *first
+		}
+	}
+
+	if !from.After(yesterday) {
+		totals, err := dailyTotals(ctx, m.DB, userID, from, yesterday, loc)
+		if err != nil {
+			return nil, err
+		}
+		for day := from; !day.After(yesterday); day = day.AddDate(0, 0, 1) {
+			state.advance(day, qualifies(kind, threshold, totals[day.Format(dateLayout)]))
+		}
+	}
+
+	// Skip the write when the cache was already up to date.
+	if cache && (state != cached || from.Before(today) || cached.staleFrom != nil) {
+		state.computedThrough = yesterday
+
+		var staleRead *time.Time
+		if cached != nil {
+			staleRead = cached.staleFrom
+		}
+		if err := m.saveState(ctx, userID, kind, state, staleRead); err != nil {
+			return nil, err
+		}
+	}
+
+	// Today is still in progress, so it is added on top of the cached state
+	// rather than stored in it.
+	todayTotals, err := dailyTotals(ctx, m.DB, userID, today, today, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	streak := &Streak{
+		Kind:      kind,
+		Threshold: threshold,
+		Current:   state.runLength,
+		Longest:   state.longestLength,
+	}
+	currentStart, currentEnd := state.runStart, &yesterday
+	switch {
+	case qualifies(kind, threshold, todayTotals[today.Format(dateLayout)]):
+		streak.Current++
+		if streak.Current == 1 {
+			currentStart = &today
+		}
+		currentEnd = &today
+	case kind == StreakUnderLimit:
+		streak.Current = 0
+	}
+
+	if streak.Current > 0 {
+		streak.CurrentStart = formatDate(currentStart)
+	}
+	if streak.Current > state.longestLength {
+		streak.Longest = streak.Current
+		streak.LongestStart = formatDate(currentStart)
+		streak.LongestEnd = formatDate(currentEnd)
+	} else if state.longestLength > 0 {
+		streak.LongestStart = formatDate(state.longestStart)
+		streak.LongestEnd = formatDate(state.longestEnd)
+	}
+
+	return streak, nil
+}
+
+// usable reports whether the cached state can be extended: it was computed
+// with the same threshold and time zone, and no write since has touched a day
+// it already covers.
+func (s *streakState) usable(threshold int, loc *time.Location) bool {
+	if s.threshold != threshold || s.timeZone != loc.String() {
+		return false
+	}
+	if s.staleFrom == nil {
+		return true
+	}
+
+	next := s.computedThrough.AddDate(0, 0, 1)
+	firstUncovered := time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, loc)
+	return !s.staleFrom.Before(firstUncovered)
+}
+
+// advance extends the state by one day.
+func (s *streakState) advance(day time.Time, ok bool) {
+	if !ok {
+		s.runLength = 0
+		s.runStart = nil
+		return
+	}
+
+	if s.runLength == 0 {
+		start := day
+		s.runStart = &start
+	}
+	s.runLength++
+
+	if s.runLength > s.longestLength {
+		end := day
+		s.longestLength = s.runLength
+		s.longestStart = s.runStart
+		s.longestEnd = &end
+	}
+}
+
+func (m *StreakModel) loadState(ctx context.Context, userID int, kind string, threshold int) (*streakState, error) {
+	query := `
+		SELECT threshold, time_zone, computed_through, run_length, run_start,
+			longest_length, longest_start, longest_end, stale_from
+		FROM user_streaks
+		WHERE user_id = $1 AND kind = $2 AND threshold = $3
+	`
+
+	var s streakState
+	err := m.DB.QueryRow(ctx, query, userID, kind, threshold).Scan(
+		&s.threshold,
+		&s.timeZone,
+		&s.computedThrough,
+		&s.runLength,
+		&s.runStart,
+		&s.longestLength,
+		&s.longestStart,
+		&s.longestEnd,
+		&s.staleFrom,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// saveState stores the state unless another write marked the row stale after
+// it was loaded (staleRead is the stale_from that was read), in which case
+// the next request rebuilds it.
+func (m *StreakModel) saveState(ctx context.Context, userID int, kind string, s *streakState, staleRead *time.Time) error {
+	query := `
+		INSERT INTO user_streaks (
+			user_id, kind, threshold, time_zone, computed_through, run_length,
+			run_start, longest_length, longest_start, longest_end
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (user_id, kind, threshold) DO UPDATE
+		SET time_zone = EXCLUDED.time_zone,
+			computed_through = EXCLUDED.computed_through,
+			run_length = EXCLUDED.run_length,
+			run_start = EXCLUDED.run_start,
+			longest_length = EXCLUDED.longest_length,
+			longest_start = EXCLUDED.longest_start,
+			longest_end = EXCLUDED.longest_end,
+			stale_from = NULL,
+			updated_at = NOW()
+		WHERE user_streaks.stale_from IS NOT DISTINCT FROM $11
+	`
+	_, err := m.DB.Exec(ctx, query,
+		userID, kind, s.threshold, s.timeZone, s.computedThrough, s.runLength,
+		s.runStart, s.longestLength, s.longestStart, s.longestEnd, staleRead,
+	)
+	return err
+}
+
+// firstEntryDate returns the local date of the user's earliest counted entry,
+// or nil if they have none.
+func firstEntryDate(ctx context.Context, db *pgxpool.Pool, userID int, loc *time.Location) (*time.Time, error) {
+	query := `
+		SELECT (MIN(eaten_at) AT TIME ZONE $2)::date
+		FROM eggcount
+		WHERE user_id = $1 AND ` + countedEntries
+
+	var first *time.Time
+	if err := db.QueryRow(ctx, query, userID, loc.String()).Scan(&first); err != nil {
+		return nil, err
+	}
+	return first, nil
+}
+
+func qualifies(kind string, threshold, total int) bool {
+	if kind == StreakUnderLimit {
+		return total <= threshold
+	}
+	return total >= threshold
+}
+
+func formatDate(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(dateLayout)
+	return &s
+}
@@ -0,0 +1,278 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Takeout statuses stored in takeouts.status.
+const (
+	TakeoutPending = "pending"
+	TakeoutRunning = "running"
+	TakeoutReady   = "ready"
+	TakeoutFailed  = "failed"
+	TakeoutExpired = "expired"
+)
+
+// takeoutStaleAfter is how long a running takeout may go without finishing
+// before another worker picks it up again, e.g. after a crash.
+const takeoutStaleAfter = 15 * time.Minute
+
+// Takeout is a request for an archive of all of a user's data.
+type Takeout struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"-"`
+	Status      string     `json:"status"`
+	Size        int        `json:"size"`
+	Error       *string    `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"-"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// TakeoutProfile is the users row as it appears in a takeout.
+type TakeoutProfile struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TakeoutFriendship is a friends row involving the user, in either direction.
+type TakeoutFriendship struct {
+	ID            int        `json:"id"`
+	Direction     string     `json:"direction"`
+	OtherUserID   int        `json:"other_user_id"`
+	OtherUsername string     `json:"other_username"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	RespondedAt   *time.Time `json:"responded_at"`
+}
+
+// TakeoutModel handles database operations for the takeouts table and reads
+// the rows that go into an archive.
+type TakeoutModel struct {
+	DB *pgxpool.Pool
+}
+
+// NewTakeoutModel creates a new instance of TakeoutModel.
+func NewTakeoutModel(db *pgxpool.Pool) *TakeoutModel {
+	return &TakeoutModel{DB: db}
+}
+
+const takeoutColumns = `id, user_id, status, COALESCE(octet_length(archive), 0), error,
+	created_at, started_at, completed_at, expires_at`
+
+func scanTakeout(row pgx.Row, t *Takeout) error {
+	return row.Scan(&t.ID, &t.UserID, &t.Status, &t.Size, &t.Error, &t.CreatedAt, &t.StartedAt, &t.CompletedAt, &t.ExpiresAt)
+}
+
+// CreateTakeout queues a takeout for the user, downloadable later with the
+// token whose hash is tokenHash. It returns ErrTakeoutInProgress if one is
+// already queued or running.
+func (m *TakeoutModel) CreateTakeout(ctx context.Context, userID int, tokenHash []byte) (*Takeout, error) {
+	query := `
+		INSERT INTO takeouts (user_id, token_hash)
+		VALUES ($1, $2)
+		RETURNING ` + takeoutColumns
+
+	var t Takeout
+	err := scanTakeout(m.DB.QueryRow(ctx, query, userID, tokenHash), &t)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return nil, ErrTakeoutInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTakeout retrieves one of the user's takeouts. It returns ErrNoRecord if
+// the takeout doesn't exist or belongs to another user.
+func (m *TakeoutModel) GetTakeout(ctx context.Context, userID, takeoutID int) (*Takeout, error) {
+	query := `
+		SELECT ` + takeoutColumns + `
+		FROM takeouts
+		WHERE id = $1 AND user_id = $2
+	`
+
+	var t Takeout
+	err := scanTakeout(m.DB.QueryRow(ctx, query, takeoutID, userID), &t)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetArchive retrieves the archive of a ready, unexpired takeout of the user
+// by its download token hash. It returns ErrNoRecord otherwise.
+func (m *TakeoutModel) GetArchive(ctx context.Context, userID int, tokenHash []byte) (*Takeout, []byte, error) {
+	query := `
+		SELECT ` + takeoutColumns + `, archive
+		FROM takeouts
+		WHERE user_id = $1 AND token_hash = $2 AND status = 'ready' AND expires_at > NOW()
+	`
+
+	var t Takeout
+	var archive []byte
+	err := m.DB.QueryRow(ctx, query, userID, tokenHash).Scan(
+		&t.ID, &t.UserID, &t.Status, &t.Size, &t.Error, &t.CreatedAt, &t.CompletedAt, &t.ExpiresAt, &archive,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &t, archive, nil
+}
+
+// ClaimTakeout marks the oldest pending takeout as running and returns it,
+// or nil if there is nothing to do. Takeouts left running by a worker that
+// died are claimed again once they go stale.
+func (m *TakeoutModel) ClaimTakeout(ctx context.Context) (*Takeout, error) {
+	query := `
+		UPDATE takeouts
+		SET status = 'running', started_at = NOW()
+		WHERE id = (
+			SELECT id FROM takeouts
+			WHERE status = 'pending' OR (status = 'running' AND started_at < $1)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + takeoutColumns
+
+	var t Takeout
+	err := scanTakeout(m.DB.QueryRow(ctx, query, time.Now().Add(-takeoutStaleAfter)), &t)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CompleteTakeout stores the finished archive of a claimed takeout,
+// downloadable for ttl. It returns ErrTakeoutReclaimed if the claim went
+// stale and another worker has since taken the takeout over.
+func (m *TakeoutModel) CompleteTakeout(ctx context.Context, claim *Takeout, archive []byte, ttl time.Duration) error {
+	query := `
+		UPDATE takeouts
+		SET status = 'ready', archive = $3, completed_at = NOW(), expires_at = $4
+		WHERE id = $1 AND status = 'running' AND started_at = $2
+	`
+	tag, err := m.DB.Exec(ctx, query, claim.ID, claim.StartedAt, archive, time.Now().Add(ttl))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTakeoutReclaimed
+	}
+	return nil
+}
+
+// FailTakeout records that building a claimed takeout failed. Like
+// CompleteTakeout it returns ErrTakeoutReclaimed for a stale claim.
+func (m *TakeoutModel) FailTakeout(ctx context.Context, claim *Takeout, reason string) error {
+	query := `
+		UPDATE takeouts
+		SET status = 'failed', error = $3, completed_at = NOW()
+		WHERE id = $1 AND status = 'running' AND started_at = $2
+	`
+	tag, err := m.DB.Exec(ctx, query, claim.ID, claim.StartedAt, reason)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTakeoutReclaimed
+	}
+	return nil
+}
+
+// ExpireTakeouts drops the archives of takeouts whose download link has
+// expired, keeping the rows as a record.
+func (m *TakeoutModel) ExpireTakeouts(ctx context.Context) (int64, error) {
+	query := `
+		UPDATE takeouts
+		SET status = 'expired', archive = NULL
+		WHERE status = 'ready' AND expires_at <= NOW()
+	`
+	tag, err := m.DB.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetProfile retrieves the user's row for a takeout.
+func (m *TakeoutModel) GetProfile(ctx context.Context, userID int) (*TakeoutProfile, error) {
+	query := `
+		SELECT id, username, email, first_name, last_name, created_at
+		FROM users
+		WHERE id = $1
+	`
+
+	var p TakeoutProfile
+	err := m.DB.QueryRow(ctx, query, userID).Scan(&p.ID, &p.Username, &p.Email, &p.FirstName, &p.LastName, &p.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetFriendships retrieves every friends row involving the user, including
+// rejected requests, oldest first.
+func (m *TakeoutModel) GetFriendships(ctx context.Context, userID int) ([]TakeoutFriendship, error) {
+	query := `
+		SELECT friends.id,
+			CASE WHEN friends.from_user_id = $1 THEN 'sent' ELSE 'received' END,
+			users.id, users.username, friends.status, friends.created_at, friends.responded_at
+		FROM friends
+		JOIN users ON users.id = CASE
+			WHEN friends.from_user_id = $1 THEN friends.to_user_id
+			ELSE friends.from_user_id
+		END
+		WHERE friends.from_user_id = $1 OR friends.to_user_id = $1
+		ORDER BY friends.created_at, friends.id
+	`
+	rows, err := m.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying friendships: %v", err)
+	}
+	defer rows.Close()
+
+	friendships := []TakeoutFriendship{}
+	for rows.Next() {
+		var f TakeoutFriendship
+		err := rows.Scan(&f.ID, &f.Direction, &f.OtherUserID, &f.OtherUsername, &f.Status, &f.CreatedAt, &f.RespondedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning friendship: %v", err)
+		}
+		friendships = append(friendships, f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return friendships, nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iankencruz/eggcounter/backend/internal/migrations"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newTestDB connects to the database named by TEST_DATABASE_URL and brings it
+// up to date with the migrations, skipping the test when it isn't set.
+func newTestDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connecting to test database: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	if _, err := migrations.NewMigrator(db).Up(ctx); err != nil {
+		t.Fatalf("migrating test database: %v", err)
+	}
+	return db
+}
+
+// createTestUser adds a user with a unique username and email, removed again
+// when the test finishes.
+func createTestUser(t *testing.T, db *pgxpool.Pool, name string) int {
+	t.Helper()
+
+	ctx := context.Background()
+	unique := fmt.Sprintf("%s_%d", name, time.Now().UnixNano())
+	query := `
+		INSERT INTO users (username, email, first_name, last_name, password_hash)
+		VALUES ($1, $2, $3, 'Test', '')
+		RETURNING id
+	`
+
+	var userID int
+	if err := db.QueryRow(ctx, query, unique, unique+"@example.com", name).Scan(&userID); err != nil {
+		t.Fatalf("creating user %s: %v", name, err)
+	}
+
+	t.Cleanup(func() {
+		if _, err := db.Exec(context.Background(), `DELETE FROM users WHERE id = $1`, userID); err != nil {
+			t.Errorf("deleting user %s: %v", name, err)
+		}
+	})
+	return userID
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ThrottlePolicy decides how long logins are blocked after repeated failures.
+type ThrottlePolicy struct {
+	// FreeAttempts failures are allowed before any delay.
+	FreeAttempts int
+	// BaseDelay is the delay after the first failure beyond FreeAttempts. It
+	// doubles with each further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAfter failures lock logins out for Lockout.
+	LockoutAfter int
+	Lockout      time.Duration
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+}
+
+// Delay returns how long to block logins after the given number of failures.
+func (p ThrottlePolicy) Delay(failures int) time.Duration {
+	if failures >= p.LockoutAfter {
+		return p.Lockout
+	}
+	if failures < p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// ThrottleModel handles database operations for the login_failures table.
+type ThrottleModel struct {
+	DB *pgxpool.Pool
+}
+
+// NewThrottleModel creates a new instance of ThrottleModel.
+func NewThrottleModel(db *pgxpool.Pool) *ThrottleModel {
+	return &ThrottleModel{DB: db}
+}
+
+// BlockedUntil returns the latest time any of keys is blocked until, or the
+// zero time if none are blocked now.
+func (m *ThrottleModel) BlockedUntil(ctx context.Context, keys []string) (time.Time, error) {
+	query := `
+		SELECT MAX(blocked_until)
+		FROM login_failures
+		WHERE key = ANY($1) AND blocked_until > NOW()
+	`
+
+	var until *time.Time
+	if err := m.DB.QueryRow(ctx, query, keys).Scan(&until); err != nil {
+		return time.Time{}, err
+	}
+	if until == nil {
+		return time.Time{}, nil
+	}
+	return *until, nil
+}
+
+// RecordFailure counts a failed login against key and blocks it as policy
+// says. It returns the number of failures in the current window and when the
+// key is blocked until, which is the zero time if it isn't.
+func (m *ThrottleModel) RecordFailure(ctx context.Context, key string, policy ThrottlePolicy) (int, time.Time, error) {
+	var failures int
+	var until time.Time
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		// Failures older than the window are forgotten, so the count starts
+		// again.
+		query := `
+			INSERT INTO login_failures (key, failures)
+			VALUES ($1, 1)
+			ON CONFLICT (key) DO UPDATE
+			SET failures = CASE
+					WHEN login_failures.last_failure_at < $2 THEN 1
+					ELSE login_failures.failures + 1
+				END,
+				last_failure_at = NOW()
+			RETURNING failures
+		`
+		if err := tx.QueryRow(ctx, query, key, time.Now().Add(-policy.Window)).Scan(&failures); err != nil {
+			return err
+		}
+
+		delay := policy.Delay(failures)
+		if delay == 0 {
+			return nil
+		}
+		until = time.Now().Add(delay)
+
+		query = `UPDATE login_failures SET blocked_until = $2 WHERE key = $1`
+		_, err := tx.Exec(ctx, query, key, until)
+		return err
+	})
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return failures, until, nil
+}
+
+// Reset forgets the failures counted against key.
+func (m *ThrottleModel) Reset(ctx context.Context, key string) error {
+	_, err := m.DB.Exec(ctx, `DELETE FROM login_failures WHERE key = $1`, key)
+	return err
+}
+
+// DeleteStale removes counters whose last failure was before cutoff and that
+// no longer block anything.
+func (m *ThrottleModel) DeleteStale(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `
+		DELETE FROM login_failures
+		WHERE last_failure_at < $1
+		  AND (blocked_until IS NULL OR blocked_until < NOW())
+	`
+	tag, err := m.DB.Exec(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
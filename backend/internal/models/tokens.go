@@ -0,0 +1,136 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Token purposes stored in user_tokens.purpose.
+const (
+	TokenPasswordReset     = "password_reset"
+	TokenEmailVerification = "email_verification"
+	TokenEmailChange       = "email_change"
+)
+
+// TokenModel handles database operations for the user_tokens table.
+type TokenModel struct {
+	DB *pgxpool.Pool
+}
+
+// NewTokenModel creates a new instance of TokenModel.
+func NewTokenModel(db *pgxpool.Pool) *TokenModel {
+	return &TokenModel{DB: db}
+}
+
+// CreateToken stores the hash of a new token for the user, valid for ttl.
+// Earlier unused tokens with the same purpose stop working, so only the most
+// recent link is ever valid.
+func (m *TokenModel) CreateToken(ctx context.Context, userID int, purpose string, tokenHash []byte, ttl time.Duration) error {
+	return pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		return createToken(ctx, tx, userID, purpose, tokenHash, ttl, nil)
+	})
+}
+
+// TokenLimit caps how often tokens of one purpose are issued to a user: no
+// more than Daily in 24 hours, and none within ResendDelay of the last.
+type TokenLimit struct {
+	ResendDelay time.Duration
+	Daily       int
+}
+
+// CreateTokenWithinLimit is CreateToken, except that it returns ErrTokenLimit
+// instead if the user has already been issued as many tokens of the purpose
+// as limit allows. The user's row stays locked from the count to the insert,
+// so concurrent requests can't both slip in under the limit.
+func (m *TokenModel) CreateTokenWithinLimit(ctx context.Context, userID int, purpose string, tokenHash []byte, ttl time.Duration, limit TokenLimit) error {
+	return pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+			return err
+		}
+
+		query := `
+			SELECT COUNT(*), MAX(created_at)
+			FROM user_tokens
+			WHERE user_id = $1 AND purpose = $2 AND created_at > $3
+		`
+		now := time.Now()
+		var count int
+		var latest *time.Time
+		if err := tx.QueryRow(ctx, query, userID, purpose, now.Add(-24*time.Hour)).Scan(&count, &latest); err != nil {
+			return err
+		}
+		if count >= limit.Daily || (latest != nil && now.Sub(*latest) < limit.ResendDelay) {
+			return ErrTokenLimit
+		}
+
+		return createToken(ctx, tx, userID, purpose, tokenHash, ttl, nil)
+	})
+}
+
+// createToken is CreateToken inside tx. email is stored with tokens that
+// confirm a new address.
+func createToken(ctx context.Context, tx pgx.Tx, userID int, purpose string, tokenHash []byte, ttl time.Duration, email *string) error {
+	query := `
+		UPDATE user_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`
+	if _, err := tx.Exec(ctx, query, userID, purpose); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at, email)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := tx.Exec(ctx, query, userID, purpose, tokenHash, time.Now().Add(ttl), email)
+	return err
+}
+
+// TokenActivity summarises the tokens of one purpose issued to a user since
+// a point in time.
+type TokenActivity struct {
+	Count  int
+	Oldest *time.Time
+	Latest *time.Time
+}
+
+// GetTokenActivity reports how many tokens with the purpose were issued to the
+// user since the given time, used to throttle emails.
+func (m *TokenModel) GetTokenActivity(ctx context.Context, userID int, purpose string, since time.Time) (*TokenActivity, error) {
+	query := `
+		SELECT COUNT(*), MIN(created_at), MAX(created_at)
+		FROM user_tokens
+		WHERE user_id = $1 AND purpose = $2 AND created_at > $3
+	`
+
+	var a TokenActivity
+	if err := m.DB.QueryRow(ctx, query, userID, purpose, since).Scan(&a.Count, &a.Oldest, &a.Latest); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// consumeToken marks an unused, unexpired token as used inside tx and
+// returns the user it belongs to, along with the email stored with it if
+// any. It returns ErrNoRecord for any other token.
+func consumeToken(ctx context.Context, tx pgx.Tx, purpose string, tokenHash []byte) (int, *string, error) {
+	query := `
+		UPDATE user_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id, email
+	`
+
+	var userID int
+	var email *string
+	err := tx.QueryRow(ctx, query, tokenHash, purpose).Scan(&userID, &email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil, ErrNoRecord
+	}
+	return userID, email, err
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCreateTokenWithinLimit(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	m := NewTokenModel(db)
+
+	alice := createTestUser(t, db, "alice")
+	limit := TokenLimit{Daily: 2}
+
+	for i := range 2 {
+		if err := m.CreateTokenWithinLimit(ctx, alice, TokenPasswordReset, []byte("alice-"+strconv.Itoa(i)), time.Hour, limit); err != nil {
+			t.Fatalf("CreateTokenWithinLimit #%d: %v", i+1, err)
+		}
+	}
+	err := m.CreateTokenWithinLimit(ctx, alice, TokenPasswordReset, []byte("alice-2"), time.Hour, limit)
+	if !errors.Is(err, ErrTokenLimit) {
+		t.Fatalf("CreateTokenWithinLimit over the daily limit = %v, want ErrTokenLimit", err)
+	}
+
+	// Concurrent requests are counted one at a time, so only one gets in.
+	bob := createTestUser(t, db, "bob")
+	limit = TokenLimit{ResendDelay: time.Minute, Daily: 5}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = m.CreateTokenWithinLimit(ctx, bob, TokenPasswordReset, []byte("bob-"+strconv.Itoa(i)), time.Hour, limit)
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrTokenLimit):
+			t.Fatalf("CreateTokenWithinLimit: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("concurrent CreateTokenWithinLimit created %d tokens, want 1", created)
+	}
+}
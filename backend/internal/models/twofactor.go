@@ -0,0 +1,185 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TwoFactorModel handles database operations for the user_totp and
+// recovery_codes tables.
+type TwoFactorModel struct {
+	DB *pgxpool.Pool
+}
+
+// NewTwoFactorModel creates a new instance of TwoFactorModel.
+func NewTwoFactorModel(db *pgxpool.Pool) *TwoFactorModel {
+	return &TwoFactorModel{DB: db}
+}
+
+// IsEnabled reports whether the user has confirmed two-factor authentication.
+func (m *TwoFactorModel) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM user_totp
+			WHERE user_id = $1 AND confirmed_at IS NOT NULL
+		)
+	`
+
+	var enabled bool
+	err := m.DB.QueryRow(ctx, query, userID).Scan(&enabled)
+	return enabled, err
+}
+
+// BeginEnrollment stores a new secret awaiting confirmation, replacing any
+// earlier unconfirmed one. It returns ErrTwoFactorEnabled if the user
+// already has two-factor authentication on.
+func (m *TwoFactorModel) BeginEnrollment(ctx context.Context, userID int, secret string) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret,
+			last_used_step = 0,
+			created_at = NOW()
+		WHERE user_totp.confirmed_at IS NULL
+	`
+	tag, err := m.DB.Exec(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+// GetSecret retrieves the user's secret. With confirmed set it only returns
+// an enabled secret, otherwise only one awaiting confirmation; either way it
+// returns ErrNoRecord if there isn't one.
+func (m *TwoFactorModel) GetSecret(ctx context.Context, userID int, confirmed bool) (string, error) {
+	query := `
+		SELECT secret FROM user_totp
+		WHERE user_id = $1 AND (confirmed_at IS NOT NULL) = $2
+	`
+
+	var secret string
+	err := m.DB.QueryRow(ctx, query, userID, confirmed).Scan(&secret)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNoRecord
+	}
+	return secret, err
+}
+
+// ConfirmEnrollment turns on two-factor authentication once the user has
+// entered a valid code for secret at step, and replaces their recovery codes
+// with recoveryHashes. It returns ErrNoRecord if that enrollment is no longer
+// waiting, for example because it was restarted.
+func (m *TwoFactorModel) ConfirmEnrollment(ctx context.Context, userID int, secret string, step int64, recoveryHashes [][]byte, source AuditSource) error {
+	return pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		query := `
+			UPDATE user_totp
+			SET confirmed_at = NOW(), last_used_step = $3
+			WHERE user_id = $1 AND secret = $2 AND confirmed_at IS NULL
+		`
+		tag, err := tx.Exec(ctx, query, userID, secret, step)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNoRecord
+		}
+
+		if err := replaceRecoveryCodes(ctx, tx, userID, recoveryHashes); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, userID, AuditTwoFactorEnabled, nil, source)
+	})
+}
+
+// UseStep records that the user logged in with the code for step. It returns
+// ErrInvalidCode if that step, or a later one, was already used, so a code
+// can't be replayed.
+func (m *TwoFactorModel) UseStep(ctx context.Context, userID int, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2
+	`
+	tag, err := m.DB.Exec(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// UseRecoveryCode marks one of the user's unused recovery codes as used and
+// returns how many are left. It returns ErrInvalidCode if no unused code
+// matches.
+func (m *TwoFactorModel) UseRecoveryCode(ctx context.Context, userID int, codeHash []byte, source AuditSource) (int, error) {
+	var remaining int
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		query := `
+			UPDATE recovery_codes
+			SET used_at = NOW()
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+		`
+		tag, err := tx.Exec(ctx, query, userID, codeHash)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrInvalidCode
+		}
+
+		query = `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+		if err := tx.QueryRow(ctx, query, userID).Scan(&remaining); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, userID, AuditRecoveryCodeUsed, nil, source)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return remaining, nil
+}
+
+// Disable turns off two-factor authentication and discards the user's
+// recovery codes.
+func (m *TwoFactorModel) Disable(ctx context.Context, userID int, source AuditSource) error {
+	return pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1 AND confirmed_at IS NOT NULL`, userID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNoRecord
+		}
+
+		if err := replaceRecoveryCodes(ctx, tx, userID, nil); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, userID, AuditTwoFactorDisabled, nil, source)
+	})
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID int, hashes [][]byte) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO recovery_codes (user_id, code_hash)
+		SELECT $1, UNNEST($2::bytea[])
+	`
+	_, err := tx.Exec(ctx, query, userID, hashes)
+	return err
+}
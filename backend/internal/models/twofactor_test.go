@@ -0,0 +1,39 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestUseStepRefusesReplay(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	m := NewTwoFactorModel(db)
+
+	userID := createTestUser(t, db, "alice")
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	const enrolledStep = 1000
+
+	if err := m.BeginEnrollment(ctx, userID, secret); err != nil {
+		t.Fatalf("BeginEnrollment: %v", err)
+	}
+	if err := m.ConfirmEnrollment(ctx, userID, secret, enrolledStep, nil, AuditSource{}); err != nil {
+		t.Fatalf("ConfirmEnrollment: %v", err)
+	}
+
+	// The step used to confirm enrollment can't be used to log in.
+	if err := m.UseStep(ctx, userID, enrolledStep); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("UseStep(enrolled step) = %v, want ErrInvalidCode", err)
+	}
+
+	if err := m.UseStep(ctx, userID, enrolledStep+1); err != nil {
+		t.Fatalf("UseStep(next step): %v", err)
+	}
+	if err := m.UseStep(ctx, userID, enrolledStep+1); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("replayed UseStep = %v, want ErrInvalidCode", err)
+	}
+	if err := m.UseStep(ctx, userID, enrolledStep); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("UseStep(earlier step) = %v, want ErrInvalidCode", err)
+	}
+}
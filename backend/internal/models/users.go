@@ -4,19 +4,33 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	ID        int
+	ID            int
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	Password      string `json:"-"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// PublicUser is the subset of a user's profile that other users may see.
+type PublicUser struct {
+	ID        int    `json:"id"`
 	Username  string `json:"username"`
-	Email     string `json:"email"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
-	Password  string `json:"-"`
-	CreatedAt string `json:"created_at"`
 }
 
 type UserModel struct {
@@ -27,20 +41,23 @@ func NewUserModel(db *pgxpool.Pool) *UserModel {
 	return &UserModel{DB: db}
 }
 
-func (m *UserModel) CreateUser(ctx context.Context, username, firstName, lastName, email, password string) error {
+// CreateUser adds an unverified account and returns its id.
+func (m *UserModel) CreateUser(ctx context.Context, username, firstName, lastName, email, password string) (int, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		fmt.Print("hashing error")
-		return err
+		return 0, err
 	}
 
 	query := `
 	INSERT INTO users (username, email, first_name, last_name, password_hash) 
-	VALUES ($1, $2, $3, $4, $5)`
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id`
 
-	_, err = m.DB.Exec(ctx, query, username, email, firstName, lastName, string(hashedPassword))
+	var userID int
+	err = m.DB.QueryRow(ctx, query, username, email, firstName, lastName, string(hashedPassword)).Scan(&userID)
 
-	return err
+	return userID, err
 }
 
 func (m *UserModel) AuthenticateUser(ctx context.Context, email, password string) (*User, error) {
@@ -58,13 +75,13 @@ func (m *UserModel) AuthenticateUser(ctx context.Context, email, password string
 	// Scan the results into the user struct
 	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.FirstName, &user.LastName, &user.Password)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrInvalidCredentials
 	}
 
 	// Compare the hashed password from DB with the user-provided password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrInvalidCredentials
 	}
 
 	return &user, nil
@@ -74,7 +91,7 @@ func (m *UserModel) GetUserByID(ctx context.Context, userID int) (*User, error)
 	var user User
 
 	query := `
-		SELECT id, username, email, first_name, last_name 
+		SELECT id, username, email, email_verified_at IS NOT NULL, first_name, last_name 
 		FROM users 
 		WHERE id = $1
 	`
@@ -83,6 +100,7 @@ func (m *UserModel) GetUserByID(ctx context.Context, userID int) (*User, error)
 		&user.ID,
 		&user.Username,
 		&user.Email,
+		&user.EmailVerified,
 		&user.FirstName,
 		&user.LastName,
 	)
@@ -93,3 +111,382 @@ func (m *UserModel) GetUserByID(ctx context.Context, userID int) (*User, error)
 
 	return &user, nil
 }
+
+// GetUserByEmail looks up a user by email address. It returns ErrNoRecord if
+// no user matches.
+func (m *UserModel) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	var user User
+
+	query := `
+		SELECT id, username, email, email_verified_at IS NOT NULL, first_name, last_name
+		FROM users
+		WHERE email = $1
+	`
+
+	err := m.DB.QueryRow(ctx, query, email).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.EmailVerified,
+		&user.FirstName,
+		&user.LastName,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetUserByUsername looks up a user's public profile by username,
+// case-insensitively. It returns ErrNoRecord if no user matches or the
+// account is scheduled for deletion.
+func (m *UserModel) GetUserByUsername(ctx context.Context, username string) (*PublicUser, error) {
+	var user PublicUser
+
+	query := `
+		SELECT id, username, first_name, last_name
+		FROM users
+		WHERE LOWER(username) = LOWER($1)
+		  AND deletion_requested_at IS NULL
+	`
+
+	err := m.DB.QueryRow(ctx, query, username).Scan(
+		&user.ID,
+		&user.Username,
+		&user.FirstName,
+		&user.LastName,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// SearchUsers finds users other than viewerID whose username starts with or
+// closely resembles q, or whose email is exactly q. Users who have blocked the
+// viewer, or whom the viewer has blocked, are left out. Closer matches are
+// returned first.
+func (m *UserModel) SearchUsers(ctx context.Context, viewerID int, q string, limit, offset int) ([]PublicUser, error) {
+	query := `
+		SELECT id, username, first_name, last_name
+		FROM users
+		WHERE id <> $1
+		  AND deletion_requested_at IS NULL
+		  AND (
+			username ILIKE $3 || '%'
+			OR username % $2
+			OR LOWER(email) = LOWER($2)
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = $1 AND blocked_id = users.id)
+			   OR (blocker_id = users.id AND blocked_id = $1)
+		  )
+		ORDER BY
+			LOWER(username) = LOWER($2) DESC,
+			username ILIKE $3 || '%' DESC,
+			similarity(username, $2) DESC,
+			username
+		LIMIT $4 OFFSET $5
+	`
+
+	rows, err := m.DB.Query(ctx, query, viewerID, q, escapeLike(q), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error searching users: %v", err)
+	}
+	defer rows.Close()
+
+	users := []PublicUser{}
+	for rows.Next() {
+		var user PublicUser
+		if err := rows.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName); err != nil {
+			return nil, fmt.Errorf("error scanning user: %v", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// CheckPassword returns ErrInvalidCredentials unless password is the user's
+// current password.
+func (m *UserModel) CheckPassword(ctx context.Context, userID int, password string) error {
+	var hash string
+	err := m.DB.QueryRow(ctx, `SELECT password_hash FROM users WHERE id = $1`, userID).Scan(&hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNoRecord
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// ScheduleDeletion marks the user's account for deletion after grace has
+// passed and returns when that will be. Scheduling an account that is
+// already scheduled keeps the original date.
+func (m *UserModel) ScheduleDeletion(ctx context.Context, userID int, grace time.Duration) (time.Time, error) {
+	query := `
+		UPDATE users
+		SET deletion_requested_at = COALESCE(deletion_requested_at, NOW()),
+			delete_after = COALESCE(delete_after, $2)
+		WHERE id = $1
+		RETURNING delete_after
+	`
+
+	var deleteAfter time.Time
+	err := m.DB.QueryRow(ctx, query, userID, time.Now().Add(grace)).Scan(&deleteAfter)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, ErrNoRecord
+	}
+	return deleteAfter, err
+}
+
+// CancelDeletion restores an account scheduled for deletion. It reports
+// whether there was anything to cancel.
+func (m *UserModel) CancelDeletion(ctx context.Context, userID int) (bool, error) {
+	query := `
+		UPDATE users
+		SET deletion_requested_at = NULL, delete_after = NULL
+		WHERE id = $1 AND delete_after IS NOT NULL
+	`
+	tag, err := m.DB.Exec(ctx, query, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// PurgeDueAccount permanently deletes one account whose grace period has
+// passed, returning its id, or 0 if none is due. The user's entries,
+// friendships and sessions are removed in the same transaction as the user,
+// so a failure leaves the account intact for the next attempt.
+func (m *UserModel) PurgeDueAccount(ctx context.Context) (int, error) {
+	var userID int
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		query := `
+			SELECT id FROM users
+			WHERE delete_after <= NOW()
+			ORDER BY delete_after
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		`
+		if err := tx.QueryRow(ctx, query).Scan(&userID); err != nil {
+			return err
+		}
+
+		// Most of these would cascade from users, but deleting them
+		// explicitly keeps the purge independent of how each foreign key is
+		// declared.
+		statements := []string{
+			`DELETE FROM eggcount WHERE user_id = $1`,
+			`DELETE FROM friends WHERE from_user_id = $1 OR to_user_id = $1`,
+			`DELETE FROM user_blocks WHERE blocker_id = $1 OR blocked_id = $1`,
+			`DELETE FROM sessions WHERE token IN (SELECT token FROM user_sessions WHERE user_id = $1)`,
+			`DELETE FROM user_sessions WHERE user_id = $1`,
+			`DELETE FROM users WHERE id = $1`,
+		}
+		for _, statement := range statements {
+			if _, err := tx.Exec(ctx, statement, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// ResetPassword sets a new password for the owner of a password reset token
+// and logs them out everywhere, returning their id. The token is used up in
+// the same transaction; ErrNoRecord means it is unknown, used or expired.
+func (m *UserModel) ResetPassword(ctx context.Context, tokenHash []byte, password string) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+
+	var userID int
+	err = pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		userID, _, err = consumeToken(ctx, tx, TokenPasswordReset, tokenHash)
+		if err != nil {
+			return err
+		}
+
+		query := `UPDATE users SET password_hash = $2 WHERE id = $1`
+		if _, err := tx.Exec(ctx, query, userID, string(hashedPassword)); err != nil {
+			return err
+		}
+
+		_, err := deleteUserSessions(ctx, tx, userID, "")
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// IsEmailVerified reports whether the user has confirmed their email address.
+func (m *UserModel) IsEmailVerified(ctx context.Context, userID int) (bool, error) {
+	query := `SELECT email_verified_at IS NOT NULL FROM users WHERE id = $1`
+
+	var verified bool
+	err := m.DB.QueryRow(ctx, query, userID).Scan(&verified)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrNoRecord
+	}
+	return verified, err
+}
+
+// VerifyEmail marks the email address of the owner of a verification token as
+// confirmed and returns their id. ErrNoRecord means the token is unknown, used
+// or expired.
+func (m *UserModel) VerifyEmail(ctx context.Context, tokenHash []byte) (int, error) {
+	var userID int
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		var err error
+		userID, _, err = consumeToken(ctx, tx, TokenEmailVerification, tokenHash)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			UPDATE users
+			SET email_verified_at = COALESCE(email_verified_at, NOW())
+			WHERE id = $1
+		`
+		_, err = tx.Exec(ctx, query, userID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// ChangePassword replaces the user's password after checking the current
+// one, returning ErrInvalidCredentials if it doesn't match. Every session
+// except the one with keepToken is logged out.
+func (m *UserModel) ChangePassword(ctx context.Context, userID int, current, password, keepToken string, source AuditSource) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		var currentHash string
+		query := `SELECT password_hash FROM users WHERE id = $1 FOR UPDATE`
+		if err := tx.QueryRow(ctx, query, userID).Scan(&currentHash); err != nil {
+			return err
+		}
+		if bcrypt.CompareHashAndPassword([]byte(currentHash), []byte(current)) != nil {
+			return ErrInvalidCredentials
+		}
+
+		query = `UPDATE users SET password_hash = $2 WHERE id = $1`
+		if _, err := tx.Exec(ctx, query, userID, string(hashedPassword)); err != nil {
+			return err
+		}
+
+		ended, err := deleteUserSessions(ctx, tx, userID, keepToken)
+		if err != nil {
+			return err
+		}
+
+		detail := map[string]string{"sessions_ended": strconv.FormatInt(ended, 10)}
+		return recordAudit(ctx, tx, userID, AuditPasswordChanged, detail, source)
+	})
+}
+
+// RequestEmailChange stores a token that will switch the user's email
+// address to email once confirmed. It returns ErrDuplicateEmail if another
+// account already uses the address.
+func (m *UserModel) RequestEmailChange(ctx context.Context, userID int, email string, tokenHash []byte, ttl time.Duration, source AuditSource) error {
+	return pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		var taken bool
+		query := `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND id <> $2)`
+		if err := tx.QueryRow(ctx, query, email, userID).Scan(&taken); err != nil {
+			return err
+		}
+		if taken {
+			return ErrDuplicateEmail
+		}
+
+		if err := createToken(ctx, tx, userID, TokenEmailChange, tokenHash, ttl, &email); err != nil {
+			return err
+		}
+
+		detail := map[string]string{"new_email": email}
+		return recordAudit(ctx, tx, userID, AuditEmailChangeRequested, detail, source)
+	})
+}
+
+// ConfirmEmailChange switches the owner of an email change token to the new
+// address it was sent to, which is verified by the act of confirming. It
+// returns ErrNoRecord if the token is unknown, used or expired, and
+// ErrDuplicateEmail if another account has taken the address since.
+func (m *UserModel) ConfirmEmailChange(ctx context.Context, tokenHash []byte, source AuditSource) (int, error) {
+	var userID int
+	err := pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		var err error
+		var email *string
+		userID, email, err = consumeToken(ctx, tx, TokenEmailChange, tokenHash)
+		if err != nil {
+			return err
+		}
+		if email == nil {
+			return ErrNoRecord
+		}
+
+		var oldEmail string
+		query := `SELECT email FROM users WHERE id = $1 FOR UPDATE`
+		if err := tx.QueryRow(ctx, query, userID).Scan(&oldEmail); err != nil {
+			return err
+		}
+
+		query = `UPDATE users SET email = $2, email_verified_at = NOW() WHERE id = $1`
+		_, err = tx.Exec(ctx, query, userID, *email)
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return ErrDuplicateEmail
+		}
+		if err != nil {
+			return err
+		}
+
+		detail := map[string]string{"old_email": oldEmail, "new_email": *email}
+		return recordAudit(ctx, tx, userID, AuditEmailChanged, detail, source)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
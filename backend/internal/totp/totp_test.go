@@ -0,0 +1,68 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key from RFC 6238 Appendix B, "12345678901234567890",
+// base32-encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCodeRFC6238 checks the Appendix B SHA1 vectors, truncated to Digits.
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at T=%d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at T=%d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := Step(now)
+
+	for offset := int64(-Skew - 1); offset <= Skew+1; offset++ {
+		code, err := Code(rfcSecret, step+offset)
+		if err != nil {
+			t.Fatalf("Code: %v", err)
+		}
+
+		got, ok := Validate(rfcSecret, code, now)
+		wantOK := offset >= -Skew && offset <= Skew
+		if ok != wantOK {
+			t.Errorf("Validate for step offset %d: ok = %v, want %v", offset, ok, wantOK)
+		}
+		if ok && got != step+offset {
+			t.Errorf("Validate for step offset %d returned step %d, want %d", offset, got, step+offset)
+		}
+	}
+}
+
+func TestValidateRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(59, 0)
+	if _, ok := Validate(rfcSecret, "287 082", now); !ok {
+		t.Fatal("Validate rejected the right code typed with a space")
+	}
+
+	for _, code := range []string{"", "28708", "2870820", "94287082", "28708a", "+28708", "２８７０８２"} {
+		if _, ok := Validate(rfcSecret, code, now); ok {
+			t.Errorf("Validate accepted %q", code)
+		}
+	}
+}